@@ -0,0 +1,66 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"github.com/pkg/errors"
+)
+
+// AwaitMode selects how long the provider waits after installing or upgrading a release.
+// It is a typed shorthand for the combination of SkipAwait and WaitForJobs.
+//
+// Helm only waits for Jobs as part of waiting for everything else, so there is no mode
+// that awaits Jobs alone: AwaitAll is the mode to choose when Jobs must finish.
+type AwaitMode string
+
+const (
+	// AwaitNone doesn't wait at all: SkipAwait is true and WaitForJobs false.
+	AwaitNone AwaitMode = "none"
+	// AwaitResources waits for the release's resources, such as Deployments, to become
+	// ready, but not for Jobs to complete. This is the provider's default.
+	AwaitResources AwaitMode = "resources"
+	// AwaitAll waits for resources to become ready and for Jobs to complete.
+	AwaitAll AwaitMode = "all"
+)
+
+// awaitSettings maps each mode to its SkipAwait and WaitForJobs settings.
+var awaitSettings = map[AwaitMode][2]bool{
+	AwaitNone:      {true, false},
+	AwaitResources: {false, false},
+	AwaitAll:       {false, true},
+}
+
+// ApplyAwaitMode sets SkipAwait and WaitForJobs from the release's Await mode, if it has
+// one. Setting either alongside Await is fine as long as they agree with it; otherwise
+// the conflict is an error, rather than having one silently win.
+func ApplyAwaitMode(args *ReleaseType) error {
+	if args.Await == nil {
+		return nil
+	}
+	settings, ok := awaitSettings[AwaitMode(*args.Await)]
+	if !ok {
+		return errors.Errorf("`await` must be one of %q, %q, or %q, got %q",
+			AwaitNone, AwaitResources, AwaitAll, *args.Await)
+	}
+	skip, jobs := settings[0], settings[1]
+	if args.SkipAwait != nil && *args.SkipAwait != skip {
+		return errors.Errorf("`skipAwait` is %t, which conflicts with `await` %q", *args.SkipAwait, *args.Await)
+	}
+	if args.WaitForJobs != nil && *args.WaitForJobs != jobs {
+		return errors.Errorf("`waitForJobs` is %t, which conflicts with `await` %q", *args.WaitForJobs, *args.Await)
+	}
+	args.SkipAwait, args.WaitForJobs = &skip, &jobs
+	return nil
+}
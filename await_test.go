@@ -0,0 +1,78 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyAwaitMode(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		r               *ReleaseType
+		skipAwait, jobs bool
+		err             string
+	}{
+		{"none", &ReleaseType{Await: strPtr("none")}, true, false, ""},
+		{"resources", &ReleaseType{Await: strPtr("resources")}, false, false, ""},
+		{"all", &ReleaseType{Await: strPtr("all")}, false, true, ""},
+		{"all with agreeing waitForJobs", &ReleaseType{Await: strPtr("all"), WaitForJobs: boolPtr(true)}, false, true, ""},
+		// Helm can't wait for Jobs without waiting for everything, so asking for Jobs alone conflicts.
+		{"jobs only", &ReleaseType{Await: strPtr("all"), SkipAwait: boolPtr(true)}, false, false,
+			"`skipAwait` is true, which conflicts with `await` \"all\""},
+		{"jobs with none", &ReleaseType{Await: strPtr("none"), WaitForJobs: boolPtr(true)}, false, false,
+			"`waitForJobs` is true, which conflicts with `await` \"none\""},
+		{"jobs with resources", &ReleaseType{Await: strPtr("resources"), WaitForJobs: boolPtr(true)}, false, false,
+			"`waitForJobs` is true, which conflicts with `await` \"resources\""},
+		{"unknown", &ReleaseType{Await: strPtr("jobs")}, false, false, "`await` must be one of"},
+	} {
+		err := ApplyAwaitMode(tc.r)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: err = %v, want %q", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+		if *tc.r.SkipAwait != tc.skipAwait || *tc.r.WaitForJobs != tc.jobs {
+			t.Errorf("%s: skipAwait = %t, waitForJobs = %t, want %t and %t",
+				tc.name, *tc.r.SkipAwait, *tc.r.WaitForJobs, tc.skipAwait, tc.jobs)
+		}
+	}
+
+	// Without a mode, the individual options are left as they are.
+	r := &ReleaseType{WaitForJobs: boolPtr(true)}
+	if err := ApplyAwaitMode(r); err != nil || r.SkipAwait != nil || !*r.WaitForJobs {
+		t.Errorf("no mode: err = %v, skipAwait = %v, waitForJobs = %v", err, r.SkipAwait, *r.WaitForJobs)
+	}
+}
+
+func TestAwaitAllReachesRelease(t *testing.T) {
+	mocks := &testMocks{}
+	if _, err := constructMocked(t, mocks, &testChart{}, &testArgs{Helm: &ReleaseType{Await: strPtr("all")}}); err != nil {
+		t.Fatal(err)
+	}
+	rel := mocks.release(t)
+	if v := rel.Inputs["waitForJobs"]; !v.IsBool() || !v.BoolValue() {
+		t.Errorf("waitForJobs = %v, want true", v)
+	}
+	if v := rel.Inputs["skipAwait"]; !v.IsBool() || v.BoolValue() {
+		t.Errorf("skipAwait = %v, want false", v)
+	}
+}
@@ -15,6 +15,9 @@
 package helmbase
 
 import (
+	"context"
+	"reflect"
+
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
@@ -23,8 +26,14 @@ import (
 )
 
 const (
-	FieldHelmStatusOutput = "status"
-	FieldHelmOptionsInput = "helmOptions"
+	FieldHelmStatusOutput        = "status"
+	FieldHelmReleaseOutput       = "release"
+	FieldHelmReleaseNameOutput   = "releaseName"
+	FieldHelmImagesOutput        = "images"
+	FieldHelmResourceNamesOutput = "resourceNames"
+	FieldHelmOperationOutput     = "operation"
+	FieldHelmManifestOutput      = "manifest"
+	FieldHelmOptionsInput        = "helmOptions"
 )
 
 // Chart represents a strongly typed Helm Chart resource. For the most part,
@@ -41,6 +50,46 @@ type Chart interface {
 	DefaultChartName() string
 	// DefaultRepo returns the default Helm repo URL for this chart.
 	DefaultRepoURL() string
+	// DefaultNamespace returns the namespace this chart is conventionally installed into,
+	// used when the user doesn't specify one. An empty string means there is no default.
+	DefaultNamespace() string
+}
+
+// ReleaseArgsAmender may optionally be implemented by a Chart to observe and mutate the
+// final Helm ReleaseArgs right before the Release is created. This is an escape hatch for
+// setting fields that helmbase doesn't model yet. Returning an error aborts construction.
+type ReleaseArgsAmender interface {
+	AmendReleaseArgs(args *helmv3.ReleaseArgs) error
+}
+
+// ReleaseResourceNamer may optionally be implemented by a Chart to choose the Pulumi name
+// of its Helm Release child resource, given the component's name, for instance to match
+// a release being imported. Returning an empty string keeps the default, name+"-helm".
+type ReleaseResourceNamer interface {
+	ReleaseResourceName(name string) string
+}
+
+// OutputsEnricher may optionally be implemented by a Chart to register outputs of its own,
+// derived from the release status, such as an endpoint URL. They're registered alongside
+// the built-in outputs, whose names they must not reuse.
+type OutputsEnricher interface {
+	EnrichOutputs(out helmv3.ReleaseStatusOutput) pulumi.Map
+}
+
+// ReleaseSetter may optionally be implemented by a Chart to receive the Helm Release child
+// resource once it has been created, for instance to expose it so that other resources can
+// depend on it or read its ResourceNames.
+type ReleaseSetter interface {
+	SetRelease(rel *helmv3.Release)
+}
+
+// ReleaseProviderer may optionally be implemented by a Chart to supply the Kubernetes
+// provider its Helm Release uses, for settings that only exist at the provider level,
+// such as suppressing Helm hooks globally. The chart may return a provider it already
+// holds or build one on the spot, e.g. with kubernetes.NewProvider parented to the given
+// component. Returning nil keeps the provider the Release inherits from the component.
+type ReleaseProviderer interface {
+	ReleaseProvider(ctx *pulumi.Context, parent pulumi.Resource) (pulumi.ProviderResource, error)
 }
 
 // ReleaseType added because it was deprecated upstream.
@@ -71,7 +120,7 @@ type ReleaseType struct {
 	Keyring *string `pulumi:"keyring"`
 	// Run helm lint when planning.
 	Lint *bool `pulumi:"lint"`
-	// The rendered manifests as JSON. Not yet supported.
+	// The rendered manifests as JSON. This is an output of the release, exposed as the component's `manifest` output; it is never sent to Helm as an input.
 	Manifest map[string]interface{} `pulumi:"manifest"`
 	// Limit the maximum number of revisions saved per release. Use 0 for no limit.
 	MaxHistory *int `pulumi:"maxHistory"`
@@ -103,7 +152,7 @@ type ReleaseType struct {
 	Status helmv3.ReleaseStatus `pulumi:"status"`
 	// Time in seconds to wait for any individual kubernetes operation.
 	Timeout *int `pulumi:"timeout"`
-	// List of assets (raw yaml files). Content is read and merged with values. Not yet supported.
+	// List of assets (raw yaml files). Content is read and merged with values. Local file and inline text assets are merged in order, so later files win, and `values` win over all files. Any others, such as remote assets, are passed on for the provider to read.
 	ValueYamlFiles []pulumi.AssetOrArchive `pulumi:"valueYamlFiles"`
 	// Custom values set for the release.
 	Values map[string]interface{} `pulumi:"values"`
@@ -113,6 +162,63 @@ type ReleaseType struct {
 	Version *string `pulumi:"version"`
 	// Will wait until all Jobs have been completed before marking the release as successful. This is ignored if `skipAwait` is enabled.
 	WaitForJobs *bool `pulumi:"waitForJobs"`
+
+	// The remaining fields are helmbase extensions. They control how helmbase prepares the
+	// release and are not passed through to the Helm Release resource.
+
+	// If set, fetch the repository's `index.yaml` before installing and verify that the chart (and version, if set) exists. Off by default to avoid the network cost.
+	ValidateRepoIndex *bool `pulumi:"validateRepoIndex"`
+	// If set, stamp the `pulumi.com/stack` and `pulumi.com/project` labels onto the release's `commonLabels` value. Off by default, since it changes the values of existing releases and charts whose values schema disallows unknown keys reject it.
+	StackLabels *bool `pulumi:"stackLabels"`
+	// Default pod affinity, merged into the `affinity` value for charts that support it, unless the chart values already set it.
+	Affinity map[string]interface{} `pulumi:"affinity"`
+	// Default pod topology spread constraints, merged into the `topologySpreadConstraints` value for charts that support it, unless the chart values already set it.
+	TopologySpreadConstraints []interface{} `pulumi:"topologySpreadConstraints"`
+	// How arrays at the given dotted value paths are combined when layering default values underneath the user's: `replace` (the default) or `append`.
+	ArrayMergeStrategies map[string]string `pulumi:"arrayMergeStrategies"`
+	// Additional public keyrings used for verification, combined with `keyring` into a single keyring when `verify` is true.
+	Keyrings []string `pulumi:"keyrings"`
+	// The pulumi-kubernetes provider plugin version to create the release with. Options set on the release are checked against it.
+	ProviderVersion *string `pulumi:"providerVersion"`
+	// Default NetworkPolicy settings, merged into the `networkPolicy` value for charts that support it, unless the chart values already set it.
+	NetworkPolicy map[string]interface{} `pulumi:"networkPolicy"`
+	// Values given as an ordered list of entries keyed by dotted path (as with `helm --set`), applied in sequence on top of `values`.
+	OrderedValues []KV `pulumi:"orderedValues"`
+	// If set alongside `createNamespace`, warn when the namespace already exists and appears to be managed by another Pulumi resource. A namespace that doesn't exist yet is no collision.
+	CheckNamespaceCollision *bool `pulumi:"checkNamespaceCollision"`
+	// If set, and the chart is a local directory, verify that its declared dependencies can be resolved before installing.
+	ValidateDependencies *bool `pulumi:"validateDependencies"`
+	// If set, and the chart lists the CRDs it installs, set `skipCrds` on install when they all already exist in the cluster. An explicit `skipCrds` always wins.
+	SkipExistingCrds *bool `pulumi:"skipExistingCrds"`
+	// Default container resource requests and limits, e.g. `{"requests": {"cpu": "100m"}}`, merged into the `resources` value for charts that support it, unless the chart values already set them.
+	DefaultResources map[string]interface{} `pulumi:"defaultResources"`
+	// The expected SHA-256 checksum of the repository's `index.yaml`, hex-encoded. If set, the index is fetched before installing, as with `validateRepoIndex`, and must match exactly.
+	RepoIndexChecksum *string `pulumi:"repoIndexChecksum"`
+	// The release channel, such as `stable` or `canary`, stamped onto the release's `commonLabels` value. Charts may restrict it to a set of allowed channels.
+	Channel *string `pulumi:"channel"`
+	// Names of CRDs, e.g. `certificates.cert-manager.io`, that must already exist in the cluster before the chart is installed.
+	RequiredCRDs []string `pulumi:"requiredCRDs"`
+	// If set, warn about `values` keys, such as dotted ones, that aren't valid Helm value identifiers. Keys within label, annotation, and selector maps are exempt.
+	ValidateValueKeys *bool `pulumi:"validateValueKeys"`
+	// How long to wait after installing or upgrading: `none`, `resources` (the default), or `all`, which also waits for Jobs to complete. A shorthand for `skipAwait` and `waitForJobs`, which must agree with it if also set.
+	Await *string `pulumi:"await"`
+	// Pulumi resource options for the Helm Release child resource: `protect`, `retainOnDelete`, `deleteBeforeReplace`, `ignoreChanges`, `replaceOnChanges`, and `customTimeouts`.
+	ResourceOptions *ReleaseResourceOptions `pulumi:"resourceOptions"`
+	// If set, fail construction if helmbase logs any warnings before creating the release, as strict CI pipelines may want. Warnings that depend on cluster state, such as namespace collisions, are only logged.
+	WarningsAsErrors *bool `pulumi:"warningsAsErrors"`
+	// Chart versions that must not be installed, each an exact version such as `1.2.3` or a range such as `>=1.0.0 <1.2.0`. Only checked when `version` is set.
+	DeniedVersions []string `pulumi:"deniedVersions"`
+	// If set, warn when the release, which must have a `name`, is stuck in a `pending-install`, `pending-upgrade`, or `pending-rollback` state left by an interrupted Helm operation, since Helm refuses to change it.
+	CheckStuckRelease *bool `pulumi:"checkStuckRelease"`
+	// If set, as with `checkStuckRelease`, but on update also delete the stuck revision so that Helm retries the install, or upgrades from the last good revision.
+	RecoverStuckRelease *bool `pulumi:"recoverStuckRelease"`
+
+	// defaultValues records the leaf values contributed by defaults rather than the user,
+	// keyed by dotted path. See ValueProvenance.
+	defaultValues map[string]interface{}
+	// secretValuePatterns, once Values hold the contents of value files, are the secret key
+	// patterns whose values To marks secret. See mergeAssetValueFiles.
+	secretValuePatterns []string
 }
 
 // ChartArgs is a properly annotated structure (with `pulumi:""` and `json:""` tags)
@@ -126,8 +232,90 @@ type ChartArgs interface {
 // the boilerplate so that the calling component can be relatively simple.
 func Construct(ctx *pulumi.Context, c Chart, typ, name string,
 	args ChartArgs, inputs provider.ConstructInputs, opts pulumi.ResourceOption) (*provider.ConstructResult, error) {
+	res, err := ConstructExt(ctx, c, typ, name, args, inputs, opts)
+	if err != nil {
+		return nil, err
+	}
+	return res.ConstructResult, nil
+}
 
-	// Ensure we have the right token.
+// ConstructContext behaves like Construct, but gives up as soon as goCtx is cancelled; see
+// ConstructExtContext.
+func ConstructContext(goCtx context.Context, ctx *pulumi.Context, c Chart, typ, name string,
+	args ChartArgs, inputs provider.ConstructInputs, opts pulumi.ResourceOption) (*provider.ConstructResult, error) {
+	res, err := ConstructExtContext(goCtx, ctx, c, typ, name, args, inputs, opts)
+	if err != nil {
+		return nil, err
+	}
+	return res.ConstructResult, nil
+}
+
+// ConstructResultExt is the result of ConstructExt. It embeds the RPC-compatible result
+// returned by Construct, alongside the Helm Release that was created for the chart.
+type ConstructResultExt struct {
+	*provider.ConstructResult
+	// Release is the underlying Helm Release child resource.
+	Release *helmv3.Release
+}
+
+// Status returns the status of the underlying Helm Release.
+func (r *ConstructResultExt) Status() helmv3.ReleaseStatusOutput {
+	return r.Release.Status
+}
+
+// Revision returns the revision number of the deployed Helm Release.
+func (r *ConstructResultExt) Revision() pulumi.IntPtrOutput {
+	return r.Release.Status.Revision()
+}
+
+// Version returns the version of the chart that was deployed.
+func (r *ConstructResultExt) Version() pulumi.StringPtrOutput {
+	return r.Release.Status.Version()
+}
+
+// GetResourceNames returns the names of the resources the release created, grouped by
+// "kind/version" as Helm reports them.
+func (r *ConstructResultExt) GetResourceNames() pulumi.StringArrayMapOutput {
+	return r.Release.ResourceNames
+}
+
+// ConstructExt behaves like Construct, but returns an extended result that also exposes
+// the created Helm Release for callers that need to do more with it, e.g. to export its
+// status as a stack output or make other resources depend on it.
+//
+// The component is registered with exactly the options supplied in opts, so a parent set
+// there (e.g. via pulumi.Parent) places the component under that resource. The Helm
+// Release is always parented to the component itself.
+//
+// If the chart implements FailureMessageTemplater, any error is reworded using its template.
+func ConstructExt(ctx *pulumi.Context, c Chart, typ, name string,
+	args ChartArgs, inputs provider.ConstructInputs, opts pulumi.ResourceOption) (*ConstructResultExt, error) {
+	return ConstructExtContext(context.Background(), ctx, c, typ, name, args, inputs, opts)
+}
+
+// ConstructExtContext behaves like ConstructExt, but gives up as soon as goCtx is cancelled,
+// returning its error (such as context.Canceled) rather than carrying on with work whose
+// result nobody is waiting for. Providers typically pass the context of the Construct RPC.
+// Cancellation is checked between steps, so a step already in progress runs to completion.
+func ConstructExtContext(goCtx context.Context, ctx *pulumi.Context, c Chart, typ, name string,
+	args ChartArgs, inputs provider.ConstructInputs, opts pulumi.ResourceOption) (*ConstructResultExt, error) {
+	res, err := constructExt(goCtx, ctx, c, typ, name, args, inputs, opts)
+	if err != nil {
+		return nil, formatFailure(c, args, err)
+	}
+	return res, nil
+}
+
+func constructExt(goCtx context.Context, ctx *pulumi.Context, c Chart, typ, name string,
+	args ChartArgs, inputs provider.ConstructInputs, opts pulumi.ResourceOption) (*ConstructResultExt, error) {
+	if err := goCtx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Ensure we have the right token, and that it's well formed.
+	if err := validateTypeToken(c.Type()); err != nil {
+		return nil, err
+	}
 	if et := c.Type(); typ != et {
 		return nil, errors.Errorf("unknown resource type %s; expected %s", typ, et)
 	}
@@ -137,47 +325,358 @@ func Construct(ctx *pulumi.Context, c Chart, typ, name string,
 		return nil, errors.Wrap(err, "setting args")
 	}
 
-	// Register our component resource.
-	if err := ctx.RegisterComponentResource(typ, name, c, opts); err != nil {
+	// Register our component resource, honoring any parent supplied in the options.
+	var compOpts []pulumi.ResourceOption
+	if opts != nil {
+		compOpts = append(compOpts, opts)
+	}
+	if err := ctx.RegisterComponentResource(typ, name, c, compOpts...); err != nil {
 		return nil, err
 	}
 
-	// Provide default values for the Helm Release, including the chart name, repository
-	// to pull from, and blitting the strongly typed values into the weakly typed map.
+	log := NewLogger(ctx, c)
 	relArgs := args.R()
 	if *relArgs == nil {
 		*relArgs = &ReleaseType{}
 	}
-	InitDefaults(*relArgs, c.DefaultChartName(), c.DefaultRepoURL(), args)
+
+	// In strict mode, record every warning so we can fail before creating the release.
+	// The cluster checks below report to clusterLog, which is never strict.
+	clusterLog := log
+	var strict *strictLogger
+	if isTrue((*relArgs).WarningsAsErrors) {
+		strict = &strictLogger{Logger: log}
+		log = strict
+	}
+
+	// Catch distinct charts that were accidentally given the same token.
+	if w := RegisterType(c); w != "" {
+		if err := log.Warn(w); err != nil {
+			return nil, err
+		}
+	}
+
+	// Reject invalid options, and surface any likely mistakes, before defaulting them.
+	if err := validatePositiveInts(*relArgs); err != nil {
+		return nil, err
+	}
+	if err := validateURLCredentials(*relArgs); err != nil {
+		return nil, err
+	}
+	if err := validateRepoURL(*relArgs); err != nil {
+		return nil, err
+	}
+	if err := validateOCIChart(*relArgs); err != nil {
+		return nil, err
+	}
+	if err := validateResourceNames(*relArgs); err != nil {
+		return nil, err
+	}
+	if err := validateChannel(*relArgs, c); err != nil {
+		return nil, err
+	}
+	if err := ReportWarnings(log, *relArgs); err != nil {
+		return nil, err
+	}
+	for _, w := range ClusterScopeWarnings(*relArgs, c) {
+		if err := log.Warn(w); err != nil {
+			return nil, err
+		}
+	}
+
+	// Work out the effective release configuration, reusing a cached one if possible.
+	// This may need the network or the cluster, so don't start it if we've been cancelled.
+	if err := goCtx.Err(); err != nil {
+		return nil, err
+	}
+	if err := prepareReleaseCached(ctx, c, relArgs, args); err != nil {
+		return nil, err
+	}
+	if err := validateDeniedVersions(*relArgs); err != nil {
+		return nil, err
+	}
+
+	// Let the chart rewrite the assembled values. These run on every construction, since
+	// they're code rather than configuration, and so can't take part in the cache.
+	if err := applyValueTransforms(*relArgs, c); err != nil {
+		return nil, errors.Wrap(err, "transforming values")
+	}
+
+	// Make sure any CRDs the chart relies on, but doesn't install, are already there. Like
+	// the namespace check below, this asks the cluster, so it's never cached.
+	if err := CheckRequiredCRDs(ctx, clusterLog, c, *relArgs); err != nil {
+		return nil, err
+	}
+
+	// If requested, look for another owner of the namespace we're about to create.
+	if isTrue((*relArgs).CheckNamespaceCollision) {
+		if err := CheckNamespaceCollision(ctx, clusterLog, c, *relArgs); err != nil {
+			return nil, errors.Wrap(err, "checking namespace")
+		}
+	}
+
+	// If requested, clear the way for a release an interrupted operation left stuck.
+	if err := CheckStuckRelease(ctx, clusterLog, c, *relArgs); err != nil {
+		return nil, err
+	}
+
+	// The Release is always parented to the component, along with any resource options
+	// the user asked for.
+	relOpts := append([]pulumi.ResourceOption{pulumi.Parent(c)}, (*relArgs).ResourceOptions.Options()...)
+
+	// If the release targets a specific provider version, pin it and check that
+	// everything we're about to use is supported by it.
+	if v := (*relArgs).ProviderVersion; v != nil && *v != "" {
+		warnings, err := CheckProviderCompatibility(*relArgs, *v)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range warnings {
+			if err := log.Warn(w); err != nil {
+				return nil, err
+			}
+		}
+		relOpts = append(relOpts, pulumi.Version(*v))
+	}
+
+	// Let the chart supply a provider of its own, overriding the inherited one.
+	if p, ok := c.(ReleaseProviderer); ok {
+		prov, err := p.ReleaseProvider(ctx, c)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating release provider")
+		}
+		if prov != nil {
+			relOpts = append(relOpts, pulumi.Provider(prov))
+		}
+	}
+
+	// During previews, summarize the effective release so it can be reviewed with the plan.
+	if ctx.DryRun() {
+		if err := log.Info(ReleaseSummary(*relArgs)); err != nil {
+			return nil, err
+		}
+	}
+
+	// Record what we're about to hand to Helm, naming only the top-level value keys. The
+	// engine only shows debug messages when running verbosely, e.g. with `--debug`.
+	if err := log.Debug(ResolvedArgsDebug(*relArgs)); err != nil {
+		return nil, err
+	}
+
+	// Don't go any further if strict mode turned up warnings.
+	if strict != nil {
+		if err := strict.err(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Convert to the Helm Release args, giving the chart a final chance to amend them.
+	helmArgs, err := To(*relArgs)
+	if err != nil {
+		return nil, errors.Wrap(err, "converting release args")
+	}
+	if a, ok := c.(ReleaseArgsAmender); ok {
+		if err := a.AmendReleaseArgs(helmArgs); err != nil {
+			return nil, errors.Wrap(err, "amending release args")
+		}
+	}
 
 	// Create the actual underlying Helm Chart resource.
-	rel, err := helmv3.NewRelease(ctx, name+"-helm", To(*relArgs), pulumi.Parent(c))
+	if err := goCtx.Err(); err != nil {
+		return nil, err
+	}
+	relName := name + "-helm"
+	if n, ok := c.(ReleaseResourceNamer); ok && n.ReleaseResourceName(name) != "" {
+		relName = n.ReleaseResourceName(name)
+	}
+	rel, err := helmv3.NewRelease(ctx, relName, helmArgs, relOpts...)
 	if err != nil {
 		return nil, err
 	}
 	c.SetOutputs(rel.Status)
+	if rs, ok := c.(ReleaseSetter); ok {
+		rs.SetRelease(rel)
+	}
 
-	// Finally, register the resulting Helm Release as a component output.
-	if err := ctx.RegisterResourceOutputs(c, pulumi.Map{
-		FieldHelmStatusOutput: rel,
-	}); err != nil {
+	// Finally, register the resulting Helm Release and its status as component outputs,
+	// along with any the chart derives from them.
+	outputs, err := componentOutputs(c, rel)
+	if err != nil {
 		return nil, err
 	}
+	if err := goCtx.Err(); err != nil {
+		return nil, err
+	}
+	if err := ctx.RegisterResourceOutputs(c, outputs); err != nil {
+		return nil, err
+	}
+	emitReleaseEvent(typ, name, rel)
 
-	return provider.NewConstructResult(c)
+	res, err := provider.NewConstructResult(c)
+	if err != nil {
+		return nil, err
+	}
+	return &ConstructResultExt{ConstructResult: res, Release: rel}, nil
+}
+
+// releaseOutputs returns the built-in component outputs for the given Helm Release.
+func releaseOutputs(rel *helmv3.Release) pulumi.Map {
+	return pulumi.Map{
+		FieldHelmReleaseOutput:       rel,
+		FieldHelmStatusOutput:        rel.Status,
+		FieldHelmReleaseNameOutput:   ReleaseName(rel),
+		FieldHelmImagesOutput:        Images(rel),
+		FieldHelmResourceNamesOutput: rel.ResourceNames,
+		FieldHelmOperationOutput:     Operation(rel),
+		FieldHelmManifestOutput:      rel.Manifest,
+	}
+}
+
+// componentOutputs returns the outputs registered on the component for the given Helm
+// Release: the built-in ones, plus any the chart derives as an OutputsEnricher.
+func componentOutputs(c Chart, rel *helmv3.Release) (pulumi.Map, error) {
+	outputs := releaseOutputs(rel)
+	if e, ok := c.(OutputsEnricher); ok {
+		for k, v := range e.EnrichOutputs(rel.Status) {
+			if _, ok := outputs[k]; ok {
+				return nil, errors.Errorf("enriched output %q conflicts with a built-in output", k)
+			}
+			outputs[k] = v
+		}
+	}
+	return outputs, nil
 }
 
-// InitDefaults copies the default chart, repo, and values onto the args struct.
-func InitDefaults(args *ReleaseType, chart, repo string, values interface{}) {
+// prepareRelease computes the effective configuration for the release, applying the
+// chart's defaults and layering the various sources of values together.
+func prepareRelease(ctx *pulumi.Context, c Chart, rel *ReleaseType, args ChartArgs) error {
+	// Value files sit directly underneath the user's inline values, as they do in Helm.
+	// Check them all first, so a bad file is reported before any are merged.
+	if err := ValidateValueFiles(rel.ValueYamlFiles); err != nil {
+		return errors.Wrap(err, "validating value files")
+	}
+	if err := mergeAssetValueFiles(rel, RedactionPatterns(c)); err != nil {
+		return errors.Wrap(err, "merging value files")
+	}
+
+	// If the chart renders values of its own (e.g. from CUE or Jsonnet), layer them
+	// underneath the user's values so that anything the user set explicitly wins.
+	if r, ok := c.(ValuesRenderer); ok {
+		rendered, err := r.Render()
+		if err != nil {
+			return errors.Wrap(err, "rendering values")
+		}
+		applyValueDefaults(rel, rendered)
+	}
+
+	// Next come the chart's curated defaults, if it has any. Each layer of defaults goes
+	// beneath those applied before it.
+	if dv, ok := c.(DefaultValuesProvider); ok {
+		applyValueDefaults(rel, dv.DefaultValues())
+	}
+
+	// If the chart ships a default values file, layer it underneath everything else.
+	if f, ok := c.(DefaultValuesFiler); ok && f.DefaultValuesFile() != "" {
+		path, err := resolveDefaultValuesFile(f.DefaultValuesFile())
+		if err != nil {
+			return err
+		}
+		defaults, err := LoadValuesFile(path)
+		if err != nil {
+			return errors.Wrap(err, "loading default values")
+		}
+		applyValueDefaults(rel, defaults)
+	}
+
+	// Provide default values for the Helm Release, including the chart name, repository
+	// to pull from, and blitting the strongly typed values into the weakly typed map.
+	var configure func(cfg *mapstructure.DecoderConfig)
+	if dc, ok := c.(ValuesDecoderConfigurer); ok {
+		configure = dc.ConfigureValuesDecoder
+	}
+	if err := initDefaults(rel, defaultChartName(c), defaultRepoURL(c), c.DefaultNamespace(), args,
+		configure, RedactionPatterns(c)); err != nil {
+		return errors.Wrap(err, "initializing defaults")
+	}
+
+	// Resolve the await shorthand into the Helm options it stands for.
+	if err := ApplyAwaitMode(rel); err != nil {
+		return err
+	}
+
+	// If requested, make sure the chart actually exists before we try to install it.
+	if isTrue(rel.ValidateRepoIndex) || rel.RepoIndexChecksum != nil {
+		if err := CheckRepoIndex(rel); err != nil {
+			return errors.Wrap(err, "validating repo index")
+		}
+	}
+
+	// Likewise, make sure a local chart's dependencies can be satisfied.
+	if isTrue(rel.ValidateDependencies) && IsLocalChart(rel.Chart) {
+		if err := CheckDependencies(rel.Chart, rel.RepositoryOpts); err != nil {
+			return errors.Wrap(err, "validating chart dependencies")
+		}
+	}
+
+	// Don't try to reinstall CRDs that are already in the cluster, if asked not to.
+	if isTrue(rel.SkipExistingCrds) {
+		ApplySkipExistingCRDs(ctx, rel, c)
+	}
+
+	// Layer any platform-wide conventional defaults the chart supports underneath the values.
+	ApplyConventionalDefaults(rel, c)
+
+	// Combine multiple keyrings into the single keyring Helm verifies against.
+	if err := applyKeyrings(rel); err != nil {
+		return errors.Wrap(err, "combining keyrings")
+	}
+
+	// If asked, stamp the originating stack and project onto the release for cluster-side
+	// auditing.
+	if isTrue(rel.StackLabels) {
+		ApplyStackLabels(rel, ctx.Project(), ctx.Stack())
+	}
+	ApplyChannelLabel(rel)
+
+	return nil
+}
+
+// ValuesDecoderConfigurer may optionally be implemented by a Chart to customize how its
+// strongly typed args are decoded into the release's values, for instance to enable
+// WeaklyTypedInput or to add decode hooks. The decoder's Result is always the release's
+// Values map, regardless of what the configurer sets. Note that mapstructure only runs a
+// DecodeHook on whole structs; ValuesDecodeHooks are run on every field's value.
+type ValuesDecoderConfigurer interface {
+	ConfigureValuesDecoder(cfg *mapstructure.DecoderConfig)
+}
+
+// InitDefaults copies the default chart, repo, namespace, and values onto the args struct.
+// An empty namespace means there is no default. It fails if the strongly typed values
+// can't be decoded into the release's values, including if any ValuesDecodeHooks fail.
+// Values whose keys match DefaultSecretKeyPatterns are redacted from its errors.
+func InitDefaults(args *ReleaseType, chart, repo, namespace string, values interface{}) error {
+	return initDefaults(args, chart, repo, namespace, values, nil, DefaultSecretKeyPatterns)
+}
+
+// initDefaults implements InitDefaults, letting the caller customize the values decoder
+// and choose the secret key patterns redacted from errors.
+func initDefaults(args *ReleaseType, chart, repo, namespace string, values interface{},
+	configure func(cfg *mapstructure.DecoderConfig), patterns []string) error {
 	// Most strongly typed charts will have a default chart name as well as a default
 	// repository location. If available, set those. The user might override these,
 	// so only initialize them if they're empty.
 	if args.Chart == "" {
 		args.Chart = chart
 	}
-	if args.RepositoryOpts.Repo == nil {
+	// OCI chart references name their registry themselves, and Helm rejects a repository
+	// alongside them, so the default repository only applies to classic charts. Charts
+	// that are only ever installed from a path or registry have no default at all.
+	if args.RepositoryOpts.Repo == nil && repo != "" && !IsOCIChart(args.Chart) {
 		args.RepositoryOpts.Repo = &repo
 	}
+	if args.Namespace == nil && namespace != "" {
+		args.Namespace = &namespace
+	}
 
 	// Blit the strongly typed values onto the weakly typed values, so that the Helm
 	// Release is constructed properly. In the event a value is present in both, the
@@ -186,22 +685,49 @@ func InitDefaults(args *ReleaseType, chart, repo string, values interface{}) {
 		args.Values = make(map[string]interface{})
 	}
 
+	// Ordered values are applied on top of the map in sequence, so later entries win.
+	ApplyOrderedValues(args.Values, args.OrderedValues)
+
 	// Decode the structure into the target map so we can copy it over to the values
 	// map, which is what the Helm Release expects. We use the `pulumi:"x"`
 	// tags to drive the naming of the resulting properties.
-	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
-		Result:  &args.Values,
+	cfg := &mapstructure.DecoderConfig{
 		TagName: "pulumi",
-	})
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+	// mapstructure only runs decode hooks on whole structs, not on the fields it copies into
+	// a map, so any ValuesDecodeHooks are run over the decoded fields afterwards. They're
+	// decoded separately so that the hooks leave the user's weakly typed values alone.
+	var decoded map[string]interface{}
+	cfg.Result = &args.Values
+	if len(ValuesDecodeHooks) > 0 {
+		cfg.Result = &decoded
+	}
+	d, err := mapstructure.NewDecoder(cfg)
 	if err != nil {
-		panic(err)
+		return errors.Wrap(err, "creating values decoder")
 	}
 	if err = d.Decode(values); err != nil {
-		panic(err)
+		return errors.Wrap(redactError(err, values, patterns), "decoding values")
+	}
+	if decoded != nil {
+		if err = applyValuesDecodeHooks(decoded); err != nil {
+			return errors.Wrap(redactError(err, values, patterns), "decoding values")
+		}
+		for k, v := range decoded {
+			args.Values[k] = v
+		}
 	}
 
 	// Delete the HelmOptions input value -- it's not helpful and would cause a cycle.
 	delete(args.Values, FieldHelmOptionsInput)
+	return nil
+}
+
+func isTrue(p *bool) bool {
+	return p != nil && *p
 }
 
 func toBoolPtr(p *bool) pulumi.BoolPtrInput {
@@ -227,63 +753,144 @@ func toStringPtr(p *string) pulumi.StringPtrInput {
 
 func toAssetOrArchiveArray(a []pulumi.AssetOrArchive) pulumi.AssetOrArchiveArray {
 	var res pulumi.AssetOrArchiveArray
-	// TODO: ?!?!?!
-	// cannot use e (variable of type pulumi.AssetOrArchive) as pulumi.AssetOrArchiveInput value in argument to append
-	/*
-		for _, e := range a {
-			res = append(res, e)
+	for _, e := range a {
+		// Every concrete Asset and Archive is also an input, but AssetOrArchive doesn't say so.
+		if in, ok := e.(pulumi.AssetOrArchiveInput); ok {
+			res = append(res, in)
 		}
-	*/
+	}
 	return res
 }
 
 // To turns the args struct into a Helm-ready ReleaseArgs struct.
-func To(args *ReleaseType) *helmv3.ReleaseArgs {
-	// Create the Helm Release args.
-	// TODO: it would be nice to do this automatically, e.g. using reflection, etc.
-	//     This is caused by the helm.ReleaseArgs type not actually having the struct
-	//     tags we need to use it directly (not clear why this is the case!)
-	//     https://github.com/pulumi/pulumi/issues/8112
-	return &helmv3.ReleaseArgs{
-		Atomic:                   toBoolPtr(args.Atomic),
-		Chart:                    pulumi.String(args.Chart),
-		CleanupOnFail:            toBoolPtr(args.CleanupOnFail),
-		CreateNamespace:          toBoolPtr(args.CreateNamespace),
-		DependencyUpdate:         toBoolPtr(args.DependencyUpdate),
-		Description:              toStringPtr(args.Description),
-		Devel:                    toBoolPtr(args.Devel),
-		DisableCRDHooks:          toBoolPtr(args.DisableCRDHooks),
-		DisableOpenapiValidation: toBoolPtr(args.DisableOpenapiValidation),
-		DisableWebhooks:          toBoolPtr(args.DisableWebhooks),
-		ForceUpdate:              toBoolPtr(args.ForceUpdate),
-		Keyring:                  toStringPtr(args.Keyring),
-		Lint:                     toBoolPtr(args.Lint),
-		Manifest:                 pulumi.ToMap(args.Manifest),
-		MaxHistory:               toIntPtr(args.MaxHistory),
-		Name:                     toStringPtr(args.Name),
-		Namespace:                toStringPtr(args.Namespace),
-		Postrender:               toStringPtr(args.Postrender),
-		RecreatePods:             toBoolPtr(args.RecreatePods),
-		RenderSubchartNotes:      toBoolPtr(args.RenderSubchartNotes),
-		Replace:                  toBoolPtr(args.Replace),
-		RepositoryOpts: &helmv3.RepositoryOptsArgs{
-			CaFile:   toStringPtr(args.RepositoryOpts.CaFile),
-			CertFile: toStringPtr(args.RepositoryOpts.CertFile),
-			KeyFile:  toStringPtr(args.RepositoryOpts.KeyFile),
-			Password: toStringPtr(args.RepositoryOpts.Password),
-			Repo:     toStringPtr(args.RepositoryOpts.Repo),
-			Username: toStringPtr(args.RepositoryOpts.Username),
-		},
-		ResetValues:    toBoolPtr(args.ResetValues),
-		ResourceNames:  pulumi.ToStringArrayMap(args.ResourceNames),
-		ReuseValues:    toBoolPtr(args.ReuseValues),
-		SkipAwait:      toBoolPtr(args.SkipAwait),
-		SkipCrds:       toBoolPtr(args.SkipCrds),
-		Timeout:        toIntPtr(args.Timeout),
-		ValueYamlFiles: toAssetOrArchiveArray(args.ValueYamlFiles),
-		Values:         pulumi.ToMap(args.Values),
-		Verify:         toBoolPtr(args.Verify),
-		Version:        toStringPtr(args.Version),
-		WaitForJobs:    toBoolPtr(args.WaitForJobs),
+//
+// Each ReleaseType field is copied onto the ReleaseArgs field of the same name, so new
+// Helm options only need adding to ReleaseType. (ReleaseArgs lacks the `pulumi:""` tags
+// that would let us match on those instead; see https://github.com/pulumi/pulumi/issues/8112.)
+// Fields without a ReleaseArgs counterpart, such as helmbase's extensions and the Status
+// output, are skipped, as are those that ReleaseArgs only has for historical reasons but
+// which are really outputs (see releaseOutputOnlyFields). A field whose type has no known
+// input conversion is reported as an error, rather than being silently dropped.
+//
+// ValueYamlFiles are passed on as they are, in order. When called from Construct, though,
+// local file and inline text assets have already been merged into Values (see
+// mergeAssetValueFiles), so only the files helmbase can't read, such as remote assets,
+// remain.
+func To(args *ReleaseType) (*helmv3.ReleaseArgs, error) {
+	var res helmv3.ReleaseArgs
+	if err := copyInputs(reflect.ValueOf(args).Elem(), reflect.ValueOf(&res).Elem()); err != nil {
+		return nil, err
 	}
+	if args.secretValuePatterns != nil && args.Values != nil {
+		res.Values = secretKeyValues(args.Values, nil, args.secretValuePatterns)
+	}
+	return &res, nil
+}
+
+// secretKeyValues converts values into a Map input, as pulumi.ToMap does, except that the
+// value of every key matching the secret key patterns, as RedactValues matches them, is
+// marked secret, including everything nested within it.
+func secretKeyValues(values map[string]interface{}, keys []string, patterns []string) pulumi.Map {
+	res := make(pulumi.Map, len(values))
+	for k, v := range values {
+		p := append(append([]string(nil), keys...), k)
+		if isSecretKey(p, patterns) {
+			res[k] = toSecret(v)
+		} else {
+			res[k] = secretKeyValue(v, p, patterns)
+		}
+	}
+	return res
+}
+
+func secretKeyValue(v interface{}, keys []string, patterns []string) pulumi.Input {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return secretKeyValues(t, keys, patterns)
+	case []interface{}:
+		res := make(pulumi.Array, len(t))
+		for i, e := range t {
+			res[i] = secretKeyValue(e, keys, patterns)
+		}
+		return res
+	default:
+		return pulumi.ToOutput(v)
+	}
+}
+
+// toSecret wraps v in an output marked secret, as pulumi.ToSecret does. pulumi.ToSecret
+// only marks its output secret after it has started resolving it, which races with the
+// resolution, so here the value is supplied once the output has been marked.
+func toSecret(v interface{}) pulumi.Output {
+	in, resolve, _ := pulumi.NewOutput()
+	out := pulumi.ToSecret(in)
+	resolve(v)
+	return out
+}
+
+// copyInputs converts each field of the plain struct src onto the input field of the
+// same name in dst.
+func copyInputs(src, dst reflect.Value) error {
+	for i := 0; i < src.NumField(); i++ {
+		sf := src.Type().Field(i)
+		df := dst.FieldByName(sf.Name)
+		if sf.PkgPath != "" || !df.IsValid() || releaseOutputOnlyFields[sf.Name] {
+			continue
+		}
+		in, err := toInput(src.Field(i), df.Type())
+		if err != nil {
+			return errors.Wrapf(err, "converting %s", sf.Name)
+		}
+		if in.IsValid() {
+			df.Set(in)
+		}
+	}
+	return nil
+}
+
+// releaseOutputOnlyFields are the ReleaseArgs fields, by name, that hold outputs of the
+// release. To never sets them, since the provider computes them.
+var releaseOutputOnlyFields = map[string]bool{"Manifest": true}
+
+// toInput converts a plain value into an input assignable to typ. Unset pointers produce
+// the zero value, leaving the input unset.
+func toInput(v reflect.Value, typ reflect.Type) (reflect.Value, error) {
+	var in interface{}
+	switch t := v.Interface().(type) {
+	case *bool:
+		in = toBoolPtr(t)
+	case *int:
+		in = toIntPtr(t)
+	case *string:
+		in = toStringPtr(t)
+	case string:
+		in = pulumi.String(t)
+	case map[string]interface{}:
+		in = pulumi.ToMap(t)
+	case map[string][]string:
+		in = pulumi.ToStringArrayMap(t)
+	case []pulumi.AssetOrArchive:
+		in = toAssetOrArchiveArray(t)
+	default:
+		// Nested option structs, such as RepositoryOpts, become their Args counterparts.
+		if v.Kind() == reflect.Struct && typ.Kind() == reflect.Interface {
+			if args, ok := nestedArgsTypes[v.Type()]; ok {
+				p := reflect.New(args)
+				if err := copyInputs(v, p.Elem()); err != nil {
+					return reflect.Value{}, err
+				}
+				return p, nil
+			}
+		}
+		return reflect.Value{}, errors.Errorf("no input conversion from %v to %v", v.Type(), typ)
+	}
+	if in == nil {
+		return reflect.Value{}, nil
+	}
+	return reflect.ValueOf(in), nil
+}
+
+// nestedArgsTypes maps the nested option structs of ReleaseType to their Args types.
+var nestedArgsTypes = map[reflect.Type]reflect.Type{
+	reflect.TypeOf(helmv3.RepositoryOpts{}): reflect.TypeOf(helmv3.RepositoryOptsArgs{}),
 }
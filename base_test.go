@@ -0,0 +1,349 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/provider"
+)
+
+// amendingChart is a chart that amends its release args.
+type amendingChart struct {
+	pulumi.ResourceState
+	chartBase
+	amend func(args *helmv3.ReleaseArgs) error
+}
+
+func (c *amendingChart) AmendReleaseArgs(args *helmv3.ReleaseArgs) error { return c.amend(args) }
+
+func TestAmendReleaseArgsSetsUnmappedField(t *testing.T) {
+	mocks := &testMocks{}
+	c := &amendingChart{amend: func(args *helmv3.ReleaseArgs) error {
+		args.Compat = pulumi.StringPtr("true")
+		return nil
+	}}
+	if _, err := constructMocked(t, mocks, c, &testArgs{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := mocks.release(t).Inputs["compat"]; !got.IsString() || got.StringValue() != "true" {
+		t.Errorf("compat = %v, want \"true\"", got)
+	}
+}
+
+func TestAmendReleaseArgsError(t *testing.T) {
+	mocks := &testMocks{}
+	c := &amendingChart{amend: func(args *helmv3.ReleaseArgs) error {
+		return errors.New("boom")
+	}}
+	_, err := constructMocked(t, mocks, c, &testArgs{})
+	if err == nil || !strings.Contains(err.Error(), "amending release args: boom") {
+		t.Fatalf("err = %v, want amending error", err)
+	}
+	if rels := mocks.byType(testReleaseType); len(rels) != 0 {
+		t.Errorf("expected no release, got %d", len(rels))
+	}
+}
+
+func TestConstructExtCarriesRelease(t *testing.T) {
+	mocks := &testMocks{}
+	res, err := constructMocked(t, mocks, &testChart{}, &testArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ConstructResult == nil || res.Release == nil {
+		t.Fatalf("result = %+v, want a construct result and release", res)
+	}
+	if urn := resolve(t, res.Release.URN()); !strings.HasSuffix(string(urn.(pulumi.URN)), "::test-helm") {
+		t.Errorf("release URN = %v, want the test-helm release", urn)
+	}
+}
+
+func TestReleaseOutputsKeepsReleaseAndStatusDistinct(t *testing.T) {
+	res, err := constructMocked(t, &testMocks{}, &testChart{}, &testArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := releaseOutputs(res.Release)
+	rel, ok := outputs[FieldHelmReleaseOutput]
+	if !ok {
+		t.Fatalf("missing %q output", FieldHelmReleaseOutput)
+	}
+	status, ok := outputs[FieldHelmStatusOutput]
+	if !ok {
+		t.Fatalf("missing %q output", FieldHelmStatusOutput)
+	}
+	if FieldHelmReleaseOutput == FieldHelmStatusOutput {
+		t.Fatalf("release and status outputs share the key %q", FieldHelmReleaseOutput)
+	}
+	if r, ok := rel.(*helmv3.Release); !ok || r != res.Release {
+		t.Errorf("%q output = %T, want the Helm Release", FieldHelmReleaseOutput, rel)
+	}
+	if _, ok := status.(helmv3.ReleaseStatusOutput); !ok {
+		t.Errorf("%q output = %T, want the release status", FieldHelmStatusOutput, status)
+	}
+}
+
+// parentComponent is a component to parent charts under.
+type parentComponent struct {
+	pulumi.ResourceState
+}
+
+func TestConstructHonorsParent(t *testing.T) {
+	mocks := &testMocks{}
+	c := &testChart{}
+	var parentURN pulumi.URN
+	err := runMocked(t, mocks, false, func(ctx *pulumi.Context) error {
+		parent := &parentComponent{}
+		if err := ctx.RegisterComponentResource("my:index:Parent", "parent", parent); err != nil {
+			return err
+		}
+		parentURN = resolve(t, parent.URN()).(pulumi.URN)
+		_, err := ConstructExt(ctx, c, c.Type(), "test", &testArgs{}, provider.ConstructInputs{}, pulumi.Parent(parent))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comps := mocks.byType(testType)
+	if len(comps) != 1 {
+		t.Fatalf("expected 1 component, got %d", len(comps))
+	}
+	if got := comps[0].RegisterRPC.GetParent(); got != string(parentURN) {
+		t.Errorf("component parent = %q, want %q", got, parentURN)
+	}
+	if urn := resolve(t, c.URN()).(pulumi.URN); !strings.Contains(string(urn), "my:index:Parent$"+testType) {
+		t.Errorf("component URN = %s, want it parented to my:index:Parent", urn)
+	}
+}
+
+func TestToValueYamlFilesRoundTrip(t *testing.T) {
+	files := []pulumi.AssetOrArchive{
+		pulumi.NewFileAsset("values.yaml"),
+		pulumi.NewStringAsset("replicaCount: 2\n"),
+		pulumi.NewRemoteAsset("https://example.com/values.yaml"),
+		pulumi.NewFileArchive("values"),
+	}
+	res, err := To(&ReleaseType{ValueYamlFiles: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := res.ValueYamlFiles.(pulumi.AssetOrArchiveArray)
+	if len(got) != len(files) {
+		t.Fatalf("got %d value files, want %d", len(got), len(files))
+	}
+	for i, f := range got {
+		if f.(pulumi.AssetOrArchive) != files[i] {
+			t.Errorf("valueYamlFiles[%d] = %v, want %v", i, f, files[i])
+		}
+	}
+	if res, err = To(&ReleaseType{}); err != nil {
+		t.Fatal(err)
+	}
+	if in, _ := res.ValueYamlFiles.(pulumi.AssetOrArchiveArray); len(in) != 0 {
+		t.Errorf("valueYamlFiles = %v, want none", in)
+	}
+
+	// Construct merges the local files itself, so only the remote one reaches the release.
+	mocks := &testMocks{}
+	args := &testArgs{Helm: &ReleaseType{ValueYamlFiles: []pulumi.AssetOrArchive{
+		pulumi.NewStringAsset("extra: true\n"),
+		pulumi.NewRemoteAsset("https://example.com/values.yaml"),
+	}}}
+	if _, err := constructMocked(t, mocks, &testChart{}, args); err != nil {
+		t.Fatal(err)
+	}
+	passed := mocks.release(t).Inputs["valueYamlFiles"].ArrayValue()
+	if len(passed) != 1 || !passed[0].IsAsset() || passed[0].AssetValue().URI != "https://example.com/values.yaml" {
+		t.Errorf("release valueYamlFiles = %v, want just the remote asset", passed)
+	}
+	if got := mocks.releaseValues(t)["extra"]; got != true {
+		t.Errorf("values.extra = %v, want true from the merged file", got)
+	}
+}
+
+// ociChart is a chart installed from an OCI registry by default.
+type ociChart struct {
+	pulumi.ResourceState
+	chartBase
+}
+
+func (c *ociChart) DefaultChartName() string { return "oci://registry.example.com/charts/nginx" }
+
+func TestConstructOCIChartWithoutRepo(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		c          Chart
+		set, chart string
+	}{
+		{"default", &ociChart{}, "", "oci://registry.example.com/charts/nginx"},
+		{"user set", &testChart{}, "oci://registry.example.com/charts/my-nginx:1.2.5",
+			"oci://registry.example.com/charts/my-nginx:1.2.5"},
+	} {
+		mocks := &testMocks{}
+		args := &testArgs{Helm: &ReleaseType{Chart: tc.set}}
+		if _, err := constructMocked(t, mocks, tc.c, args); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		rel := mocks.release(t)
+		if got := rel.Inputs["chart"].StringValue(); got != tc.chart {
+			t.Errorf("%s: chart = %q, want %q", tc.name, got, tc.chart)
+		}
+		if opts := rel.Inputs["repositoryOpts"]; opts.IsObject() && opts.ObjectValue().HasValue("repo") {
+			t.Errorf("%s: repositoryOpts.repo = %v, want unset for an OCI chart", tc.name, opts.ObjectValue()["repo"])
+		}
+	}
+}
+
+// pathChart is a chart with no default repository, installed from a local path.
+type pathChart struct {
+	pulumi.ResourceState
+	chartBase
+}
+
+func (c *pathChart) DefaultRepoURL() string { return "" }
+
+func TestEmptyDefaultRepoLeavesRepoUnset(t *testing.T) {
+	rel := &ReleaseType{}
+	if err := InitDefaults(rel, "nginx", "", "", &testArgs{}); err != nil {
+		t.Fatal(err)
+	}
+	if rel.RepositoryOpts.Repo != nil {
+		t.Errorf("repo = %q, want unset", *rel.RepositoryOpts.Repo)
+	}
+
+	mocks := &testMocks{}
+	if _, err := constructMocked(t, mocks, &pathChart{}, &testArgs{}); err != nil {
+		t.Fatal(err)
+	}
+	if opts := mocks.release(t).Inputs["repositoryOpts"]; opts.IsObject() && opts.ObjectValue().HasValue("repo") {
+		t.Errorf("repositoryOpts.repo = %v, want unset", opts.ObjectValue()["repo"])
+	}
+
+	// A default still doesn't override the user's repo.
+	rel = &ReleaseType{RepositoryOpts: helmv3.RepositoryOpts{Repo: strPtr("https://mirror.example.com")}}
+	if err := InitDefaults(rel, "nginx", "https://charts.example.com", "", &testArgs{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := *rel.RepositoryOpts.Repo; got != "https://mirror.example.com" {
+		t.Errorf("repo = %q, want the user's mirror", got)
+	}
+}
+
+func TestManifestIsAnOutputOnly(t *testing.T) {
+	manifest := map[string]interface{}{"Deployment/default/web": map[string]interface{}{"kind": "Deployment"}}
+	mocks := &testMocks{newResource: func(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+		outs := args.Inputs.Copy()
+		if args.TypeToken == testReleaseType {
+			outs["manifest"] = resource.NewPropertyValue(manifest)
+		}
+		return args.Name + "-id", outs, nil
+	}}
+	args := &testArgs{Helm: &ReleaseType{Manifest: map[string]interface{}{"kind": "Ignored"}}}
+	res, err := constructMocked(t, mocks, &testChart{}, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := mocks.release(t).Inputs["manifest"]; ok {
+		t.Errorf("manifest input = %v, want it not sent", v)
+	}
+	out, ok := releaseOutputs(res.Release)[FieldHelmManifestOutput]
+	if !ok {
+		t.Fatalf("missing %q output", FieldHelmManifestOutput)
+	}
+	if got := resolve(t, out.(pulumi.MapOutput)); !reflect.DeepEqual(got, manifest) {
+		t.Errorf("%q output = %v, want %v", FieldHelmManifestOutput, got, manifest)
+	}
+}
+
+// namingChart is a chart that chooses its Release's resource name, keeping the default for
+// components named "default".
+type namingChart struct {
+	pulumi.ResourceState
+	chartBase
+}
+
+func (c *namingChart) ReleaseResourceName(name string) string {
+	if name == "default" {
+		return ""
+	}
+	return "imported-" + name
+}
+
+func TestReleaseResourceName(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		chart Chart
+		want  string
+	}{
+		{"test", &testChart{}, "test-helm"},
+		{"test", &namingChart{}, "imported-test"},
+		{"default", &namingChart{}, "default-helm"},
+	} {
+		mocks := &testMocks{}
+		err := runMocked(t, mocks, false, func(ctx *pulumi.Context) error {
+			_, err := ConstructExt(ctx, tc.chart, tc.chart.Type(), tc.name, &testArgs{},
+				provider.ConstructInputs{}, nil)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("%T %s: %v", tc.chart, tc.name, err)
+		}
+		if got := mocks.release(t).Name; got != tc.want {
+			t.Errorf("%T %s: release name = %q, want %q", tc.chart, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestConstructContextCancelled(t *testing.T) {
+	goCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, variant := range []struct {
+		name      string
+		construct func(ctx *pulumi.Context, c Chart) error
+	}{
+		{"ConstructContext", func(ctx *pulumi.Context, c Chart) error {
+			_, err := ConstructContext(goCtx, ctx, c, c.Type(), "test", &testArgs{}, provider.ConstructInputs{}, nil)
+			return err
+		}},
+		{"ConstructExtContext", func(ctx *pulumi.Context, c Chart) error {
+			_, err := ConstructExtContext(goCtx, ctx, c, c.Type(), "test", &testArgs{}, provider.ConstructInputs{}, nil)
+			return err
+		}},
+	} {
+		var construct error
+		mocks := &testMocks{}
+		err := runMocked(t, mocks, false, func(ctx *pulumi.Context) error {
+			construct = variant.construct(ctx, &testChart{})
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if construct != context.Canceled {
+			t.Errorf("%s: err = %v, want context.Canceled", variant.name, construct)
+		}
+		if len(mocks.resources) != 0 {
+			t.Errorf("%s: registered %d resources after cancellation, want none", variant.name, len(mocks.resources))
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/provider"
+)
+
+// BaseChart is an embeddable implementation of Chart for charts whose strongly typed args
+// are T, which must be a pointer to a struct. It supplies the Chart methods from its
+// fields, so a wrapper only needs to fill them in. The SDK only finds a
+// pulumi.ResourceState embedded directly in the component, so the wrapper embeds one
+// alongside:
+//
+//	type Nginx struct {
+//		pulumi.ResourceState
+//		helmbase.BaseChart[*NginxArgs]
+//	}
+//
+//	chart := &Nginx{BaseChart: helmbase.BaseChart[*NginxArgs]{Token: "nginx:index:Nginx", ChartName: "nginx"}}
+//	res, err := helmbase.ConstructChart[*NginxArgs](ctx, chart, typ, name, inputs, opts)
+//
+// BaseChart implements ReleaseSetter; the other optional interfaces, such as
+// ReleaseArgsAmender, may be implemented on the embedding type, which may also override
+// any of BaseChart's methods.
+type BaseChart[T ChartArgs] struct {
+	// Token is the chart's type token, returned by Type.
+	Token string
+	// ChartName, RepoURL, and Namespace are the chart's defaults, returned by
+	// DefaultChartName, DefaultRepoURL, and DefaultNamespace.
+	ChartName string
+	RepoURL   string
+	Namespace string
+
+	// Status is the status of the Helm Release, once it has been created.
+	Status helmv3.ReleaseStatusOutput `pulumi:"status"`
+	// Args are the chart's args, once ConstructChart has decoded them.
+	Args T
+
+	rel *helmv3.Release
+}
+
+func (c *BaseChart[T]) Type() string             { return c.Token }
+func (c *BaseChart[T]) DefaultChartName() string { return c.ChartName }
+func (c *BaseChart[T]) DefaultRepoURL() string   { return c.RepoURL }
+func (c *BaseChart[T]) DefaultNamespace() string { return c.Namespace }
+
+func (c *BaseChart[T]) SetOutputs(out helmv3.ReleaseStatusOutput) { c.Status = out }
+
+func (c *BaseChart[T]) setArgs(args T) { c.Args = args }
+
+// SetRelease records the Helm Release child resource; see ReleaseSetter.
+func (c *BaseChart[T]) SetRelease(rel *helmv3.Release) { c.rel = rel }
+
+// Release returns the Helm Release child resource, for instance to read its ResourceNames
+// or make other resources depend on it. It is nil until the release has been created.
+func (c *BaseChart[T]) Release() *helmv3.Release { return c.rel }
+
+// GetResourceNames returns the names of the resources the release created, grouped by
+// "kind/version" as Helm reports them. Until the release has been created, it resolves to
+// an empty map.
+func (c *BaseChart[T]) GetResourceNames() pulumi.StringArrayMapOutput {
+	if c.rel == nil {
+		return pulumi.StringArrayMap{}.ToStringArrayMapOutput()
+	}
+	return c.rel.ResourceNames
+}
+
+// The following accessors project individual fields out of Status, for instance to export
+// the deployed app version as a stack output. Fields Helm leaves unset resolve to their
+// zero value. Like Status itself, they may only be used once the release has been created.
+
+// ChartVersion returns the version of the chart that was deployed.
+func (c *BaseChart[T]) ChartVersion() pulumi.StringOutput {
+	return statusString(c.Status, func(st helmv3.ReleaseStatus) *string { return st.Version })
+}
+
+// AppVersion returns the version of the application the deployed chart packages.
+func (c *BaseChart[T]) AppVersion() pulumi.StringOutput {
+	return statusString(c.Status, func(st helmv3.ReleaseStatus) *string { return st.AppVersion })
+}
+
+// ReleaseName returns the name of the deployed Helm release.
+func (c *BaseChart[T]) ReleaseName() pulumi.StringOutput {
+	return statusString(c.Status, func(st helmv3.ReleaseStatus) *string { return st.Name })
+}
+
+// ReleaseNamespace returns the namespace the release was deployed into.
+func (c *BaseChart[T]) ReleaseNamespace() pulumi.StringOutput {
+	return statusString(c.Status, func(st helmv3.ReleaseStatus) *string { return st.Namespace })
+}
+
+// Revision returns the revision number of the deployed release.
+func (c *BaseChart[T]) Revision() pulumi.IntOutput { return statusRevision(c.Status) }
+
+// ReleaseState returns the Helm status of the release, e.g. `deployed` or `failed`.
+func (c *BaseChart[T]) ReleaseState() pulumi.StringOutput { return c.Status.Status() }
+
+// ConstructChart behaves like Construct, but allocates the chart's args of type T itself,
+// saving every chart from doing so; see NewChartArgs. A chart embedding BaseChart[T] can
+// reach its Helm Release through Release afterwards.
+func ConstructChart[T ChartArgs](ctx *pulumi.Context, c Chart, typ, name string,
+	inputs provider.ConstructInputs, opts pulumi.ResourceOption) (*provider.ConstructResult, error) {
+	args, err := NewChartArgs[T](c)
+	if err != nil {
+		return nil, err
+	}
+	return Construct(ctx, c, typ, name, args, inputs, opts)
+}
+
+// NewChartArgs allocates empty args of type T, which must be a pointer to a struct, for
+// passing to any of the Construct variants, e.g. ConstructExtContext. If the chart embeds
+// BaseChart[T], the args are also recorded in its Args field, so it sees them once decoded.
+func NewChartArgs[T ChartArgs](c Chart) (T, error) {
+	var zero T
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return zero, errors.Errorf("chart args type %v must be a pointer to a struct", t)
+	}
+	args := reflect.New(t.Elem()).Interface().(T)
+	if s, ok := c.(interface{ setArgs(T) }); ok {
+		s.setArgs(args)
+	}
+	return args, nil
+}
@@ -0,0 +1,228 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/provider"
+)
+
+// baseNginx is a chart built on BaseChart.
+type baseNginx struct {
+	pulumi.ResourceState
+	BaseChart[*testArgs]
+}
+
+func newBaseNginx() *baseNginx {
+	return &baseNginx{BaseChart: BaseChart[*testArgs]{Token: testType, ChartName: "nginx",
+		RepoURL: "https://charts.example.com"}}
+}
+
+// constructBaseChart constructs c with ConstructChart against mocks.
+func constructBaseChart(t *testing.T, mocks *testMocks, c *baseNginx) {
+	t.Helper()
+	constructBaseChartOutputs(t, mocks, c, func() []pulumi.Output { return nil })
+}
+
+// constructBaseChartOutputs behaves like constructBaseChart, but also derives outputs from
+// c with derive while the program runs, returning them once it has finished; see resolve.
+func constructBaseChartOutputs(t *testing.T, mocks *testMocks, c *baseNginx,
+	derive func() []pulumi.Output) []pulumi.Output {
+	t.Helper()
+	var outs []pulumi.Output
+	err := runMocked(t, mocks, false, func(ctx *pulumi.Context) error {
+		_, err := ConstructChart[*testArgs](ctx, c, testType, "test", provider.ConstructInputs{}, nil)
+		outs = derive()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return outs
+}
+
+func TestBaseChartRelease(t *testing.T) {
+	c := newBaseNginx()
+	if c.Release() != nil {
+		t.Fatal("release is set before construction")
+	}
+	if got := resolve(t, c.GetResourceNames()); !reflect.DeepEqual(got, map[string][]string{}) {
+		t.Errorf("GetResourceNames before construction = %#v, want an empty map", got)
+	}
+	mocks := &testMocks{}
+	constructBaseChart(t, mocks, c)
+	if c.Release() == nil {
+		t.Fatal("release is unset after construction")
+	}
+	names := resolve(t, c.Release().ResourceNames)
+	if _, ok := names.(map[string][]string); !ok {
+		t.Errorf("resourceNames = %#v, want a map of names", names)
+	}
+	if c.Args == nil {
+		t.Error("args are unset after construction")
+	}
+}
+
+func TestResourceNamesOutputRegistered(t *testing.T) {
+	names := map[string]interface{}{
+		"Deployment/apps/v1": []interface{}{"default/test-nginx"},
+		"Service/v1":         []interface{}{"default/test-nginx"},
+	}
+	want := map[string][]string{
+		"Deployment/apps/v1": {"default/test-nginx"},
+		"Service/v1":         {"default/test-nginx"},
+	}
+	mocks := &testMocks{newResource: func(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+		outs := args.Inputs.Copy()
+		if args.TypeToken == testReleaseType {
+			outs["resourceNames"] = resource.NewPropertyValue(names)
+		}
+		return args.Name + "-id", outs, nil
+	}}
+
+	c := newBaseNginx()
+	constructBaseChart(t, mocks, c)
+	out, ok := releaseOutputs(c.Release())[FieldHelmResourceNamesOutput]
+	if !ok {
+		t.Fatalf("missing %q output", FieldHelmResourceNamesOutput)
+	}
+	if got := resolve(t, out.(pulumi.StringArrayMapOutput)); !reflect.DeepEqual(got, want) {
+		t.Errorf("%q output = %v, want %v", FieldHelmResourceNamesOutput, got, want)
+	}
+	if got := resolve(t, c.GetResourceNames()); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetResourceNames = %v, want %v", got, want)
+	}
+}
+
+func TestConstructVariantsCompose(t *testing.T) {
+	c := newBaseNginx()
+	var res *ConstructResultExt
+	var status, revision pulumi.Output
+	err := runMocked(t, releaseStatusMocks(map[string]interface{}{"revision": 1}), false, func(ctx *pulumi.Context) error {
+		args, err := NewChartArgs[*testArgs](c)
+		if err != nil {
+			return err
+		}
+		res, err = ConstructExtContext(context.Background(), ctx, c, testType, "test", args,
+			provider.ConstructInputs{}, nil)
+		if err != nil {
+			return err
+		}
+		status, revision = res.Status().Status(), res.Revision()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res == nil || res.ConstructResult == nil || res.Release == nil {
+		t.Fatalf("result = %+v, want a construct result and release", res)
+	}
+	if res.Release != c.Release() {
+		t.Error("the result's release isn't the one recorded on the chart")
+	}
+	if c.Args == nil {
+		t.Error("NewChartArgs didn't record the args on the chart")
+	}
+	if got := resolve(t, status); got != "deployed" {
+		t.Errorf("status = %v, want deployed", got)
+	}
+	if got := resolve(t, revision); got == nil || *got.(*int) != 1 {
+		t.Errorf("revision = %v, want 1", got)
+	}
+
+	if _, err := NewChartArgs[ChartArgs](c); err == nil {
+		t.Error("expected an error for an args type that isn't a pointer to a struct")
+	}
+}
+
+// amendingNginx is a BaseChart chart that implements an optional interface of its own.
+type amendingNginx struct {
+	pulumi.ResourceState
+	BaseChart[*testArgs]
+}
+
+var _ ReleaseSetter = (*amendingNginx)(nil)
+
+func (c *amendingNginx) AmendReleaseArgs(args *helmv3.ReleaseArgs) error {
+	args.Description = pulumi.String("amended")
+	return nil
+}
+
+func TestBaseChartOptionalInterfaces(t *testing.T) {
+	c := &amendingNginx{BaseChart: BaseChart[*testArgs]{Token: testType, ChartName: "nginx",
+		RepoURL: "https://charts.example.com"}}
+	mocks := &testMocks{}
+	err := runMocked(t, mocks, false, func(ctx *pulumi.Context) error {
+		_, err := ConstructChart[*testArgs](ctx, c, testType, "test", provider.ConstructInputs{}, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mocks.release(t).Inputs["description"]; !got.IsString() || got.StringValue() != "amended" {
+		t.Errorf("description = %v, want the embedding type's amendment", got)
+	}
+	if c.Release() == nil {
+		t.Error("BaseChart's SetRelease wasn't called")
+	}
+}
+
+func TestBaseChartAccessors(t *testing.T) {
+	status := map[string]interface{}{
+		"name":       "web",
+		"namespace":  "apps",
+		"version":    "1.2.3",
+		"appVersion": "4.5.6",
+		"revision":   3,
+		"status":     "deployed",
+	}
+	c := newBaseNginx()
+	accessors := func() []pulumi.Output {
+		return []pulumi.Output{c.ChartVersion(), c.AppVersion(), c.ReleaseName(), c.ReleaseNamespace(),
+			c.Revision(), c.ReleaseState()}
+	}
+	outs := constructBaseChartOutputs(t, releaseStatusMocks(status), c, accessors)
+	for i, tc := range []struct {
+		name string
+		want interface{}
+	}{
+		{"ChartVersion", "1.2.3"},
+		{"AppVersion", "4.5.6"},
+		{"ReleaseName", "web"},
+		{"ReleaseNamespace", "apps"},
+		{"Revision", 3},
+		{"ReleaseState", "deployed"},
+	} {
+		if got := resolve(t, outs[i]); got != tc.want {
+			t.Errorf("%s = %#v, want %#v", tc.name, got, tc.want)
+		}
+	}
+
+	// Fields Helm leaves unset resolve to their zero value.
+	c = newBaseNginx()
+	outs = constructBaseChartOutputs(t, releaseStatusMocks(nil), c, accessors)
+	if got := resolve(t, outs[1]); got != "" {
+		t.Errorf("unset AppVersion = %#v, want empty", got)
+	}
+	if got := resolve(t, outs[4]); got != 0 {
+		t.Errorf("unset Revision = %#v, want 0", got)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+// BuildChartName and BuildRepoURL let distributions bake a chart's defaults in at build
+// time, rather than in code, using the linker:
+//
+//	go build -ldflags "-X github.com/joeduffy/pulumi-go-helmbase.BuildChartName=nginx \
+//	    -X github.com/joeduffy/pulumi-go-helmbase.BuildRepoURL=https://charts.example.com"
+//
+// Each is used only when the chart's corresponding method, DefaultChartName or
+// DefaultRepoURL, returns an empty string.
+var (
+	BuildChartName string
+	BuildRepoURL   string
+)
+
+// defaultChartName returns the chart's default name, falling back to BuildChartName.
+func defaultChartName(c Chart) string {
+	if name := c.DefaultChartName(); name != "" {
+		return name
+	}
+	return BuildChartName
+}
+
+// defaultRepoURL returns the chart's default repo URL, falling back to BuildRepoURL.
+func defaultRepoURL(c Chart) string {
+	if repo := c.DefaultRepoURL(); repo != "" {
+		return repo
+	}
+	return BuildRepoURL
+}
@@ -0,0 +1,73 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// unnamedChart is a chart with no default chart name or repo of its own.
+type unnamedChart struct {
+	pulumi.ResourceState
+	chartBase
+}
+
+func (c *unnamedChart) DefaultChartName() string { return "" }
+func (c *unnamedChart) DefaultRepoURL() string   { return "" }
+
+// setBuildVars sets BuildChartName and BuildRepoURL as `-ldflags -X` would, for the
+// duration of the test.
+func setBuildVars(t *testing.T, chart, repo string) {
+	t.Helper()
+	oldChart, oldRepo := BuildChartName, BuildRepoURL
+	BuildChartName, BuildRepoURL = chart, repo
+	t.Cleanup(func() { BuildChartName, BuildRepoURL = oldChart, oldRepo })
+}
+
+func TestBuildVars(t *testing.T) {
+	// The documented `-X` flags name the variables by this package's import path.
+	if got := reflect.TypeOf(ReleaseType{}).PkgPath(); got != "github.com/joeduffy/pulumi-go-helmbase" {
+		t.Fatalf("package path = %q, which the ldflags example must be updated to match", got)
+	}
+
+	setBuildVars(t, "baked-nginx", "https://baked.example.com")
+	mocks := &testMocks{}
+	if _, err := constructMocked(t, mocks, &unnamedChart{}, &testArgs{}); err != nil {
+		t.Fatal(err)
+	}
+	rel := mocks.release(t)
+	if got := rel.Inputs["chart"].StringValue(); got != "baked-nginx" {
+		t.Errorf("chart = %q, want the baked-in baked-nginx", got)
+	}
+	if got := rel.Inputs["repositoryOpts"].ObjectValue()["repo"].StringValue(); got != "https://baked.example.com" {
+		t.Errorf("repo = %q, want the baked-in repo", got)
+	}
+
+	// The chart's own defaults win over the baked-in ones.
+	mocks = &testMocks{}
+	if _, err := constructMocked(t, mocks, &testChart{}, &testArgs{}); err != nil {
+		t.Fatal(err)
+	}
+	rel = mocks.release(t)
+	if got := rel.Inputs["chart"].StringValue(); got != "nginx" {
+		t.Errorf("chart = %q, want the chart's own nginx", got)
+	}
+	if got := rel.Inputs["repositoryOpts"].ObjectValue()["repo"].StringValue(); got != "https://charts.example.com" {
+		t.Errorf("repo = %q, want the chart's own repo", got)
+	}
+}
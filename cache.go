@@ -0,0 +1,215 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ReleaseConfigCache stores effective release configurations, keyed by ReleaseConfigHash.
+type ReleaseConfigCache interface {
+	Get(hash string) (*ReleaseType, bool)
+	Put(hash string, rel *ReleaseType)
+}
+
+// DefaultReleaseConfigCache, if set, lets Construct skip decoding and merging values for
+// a release whose inputs hash identically to one it has prepared before. It is nil, and
+// caching disabled, by default.
+var DefaultReleaseConfigCache ReleaseConfigCache
+
+// memoryReleaseConfigCache is a ReleaseConfigCache held in memory.
+type memoryReleaseConfigCache struct {
+	mu      sync.Mutex
+	entries map[string]*ReleaseType
+}
+
+// NewMemoryReleaseConfigCache returns an empty, concurrency-safe, in-memory cache.
+func NewMemoryReleaseConfigCache() ReleaseConfigCache {
+	return &memoryReleaseConfigCache{entries: make(map[string]*ReleaseType)}
+}
+
+func (m *memoryReleaseConfigCache) Get(hash string) (*ReleaseType, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rel, ok := m.entries[hash]
+	if !ok {
+		return nil, false
+	}
+	return cloneRelease(rel), true
+}
+
+func (m *memoryReleaseConfigCache) Put(hash string, rel *ReleaseType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[hash] = cloneRelease(rel)
+}
+
+// ErrUncacheableRelease is returned by ReleaseConfigHash when a release's effective
+// configuration depends on something its inputs don't capture, such as values the chart
+// renders or the state of the cluster, and so can't safely be cached.
+var ErrUncacheableRelease = errors.New("release config depends on inputs that can't be hashed")
+
+// ReleaseConfigHash returns a stable hash of everything that determines a release's
+// effective configuration: the chart's type and defaults, the originating project and
+// stack, and the strongly typed args, including the user's release options. Assets are
+// hashed by their path, text, and URI, along with a digest of any local file's contents.
+// If the configuration also depends on anything else (see uncacheable), it returns
+// ErrUncacheableRelease.
+func ReleaseConfigHash(ctx *pulumi.Context, c Chart, args ChartArgs) (string, error) {
+	if uncacheable(c, *args.R()) {
+		return "", ErrUncacheableRelease
+	}
+	hashable, err := hashableValue(reflect.ValueOf(args))
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(struct {
+		Type, Chart, Repo, Namespace, Project, Stack string
+		Args                                         interface{}
+	}{c.Type(), defaultChartName(c), defaultRepoURL(c), c.DefaultNamespace(), ctx.Project(), ctx.Stack(), hashable})
+	if err != nil {
+		return "", errors.Wrap(err, "hashing release config")
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// uncacheable reports whether preparing the release consults anything besides its inputs:
+// values the chart computes or reads from files, the repository, or the cluster.
+func uncacheable(c Chart, rel *ReleaseType) bool {
+	switch c.(type) {
+	case ValuesRenderer, DefaultValuesProvider, DefaultValuesFiler:
+		return true
+	}
+	if rel == nil {
+		return false
+	}
+	return isTrue(rel.ValidateRepoIndex) || rel.RepoIndexChecksum != nil || isTrue(rel.ValidateDependencies) ||
+		isTrue(rel.SkipExistingCrds) || (isTrue(rel.Verify) && len(rel.Keyrings) > 0)
+}
+
+// hashableValue converts v into a form that encodes to JSON with everything that affects
+// the release, unlike Assets and Outputs, whose fields are unexported and so encode as {}.
+// It returns ErrUncacheableRelease for values it can't capture, such as Outputs.
+func hashableValue(v reflect.Value) (interface{}, error) {
+	if v.CanInterface() {
+		switch t := v.Interface().(type) {
+		case pulumi.Output:
+			return nil, ErrUncacheableRelease
+		case pulumi.Asset:
+			res := map[string]interface{}{"path": t.Path(), "text": t.Text(), "uri": t.URI()}
+			if t.Path() != "" {
+				data, err := ioutil.ReadFile(t.Path())
+				if err != nil {
+					return nil, ErrUncacheableRelease
+				}
+				sum := sha256.Sum256(data)
+				res["digest"] = hex.EncodeToString(sum[:])
+			}
+			return res, nil
+		case pulumi.Archive:
+			// Archives may be whole directories, which aren't worth digesting to save a merge.
+			return nil, ErrUncacheableRelease
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return hashableValue(v.Elem())
+	case reflect.Struct:
+		res := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			e, err := hashableValue(v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			res[v.Type().Field(i).Name] = e
+		}
+		return res, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		res := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			e, err := hashableValue(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			res[fmt.Sprint(iter.Key().Interface())] = e
+		}
+		return res, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		res := make([]interface{}, v.Len())
+		for i := range res {
+			e, err := hashableValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			res[i] = e
+		}
+		return res, nil
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return nil, ErrUncacheableRelease
+	default:
+		return v.Interface(), nil
+	}
+}
+
+// prepareReleaseCached runs prepareRelease, unless DefaultReleaseConfigCache already holds
+// the effective configuration for these inputs, in which case that is used instead.
+// Releases whose configuration can't be hashed are always prepared afresh.
+func prepareReleaseCached(ctx *pulumi.Context, c Chart, relArgs **ReleaseType, args ChartArgs) error {
+	cache := DefaultReleaseConfigCache
+	if cache == nil {
+		return prepareRelease(ctx, c, *relArgs, args)
+	}
+
+	// Hash before preparing, since preparing mutates the inputs we hash.
+	hash, err := ReleaseConfigHash(ctx, c, args)
+	if errors.Cause(err) == ErrUncacheableRelease {
+		return prepareRelease(ctx, c, *relArgs, args)
+	} else if err != nil {
+		return err
+	}
+	if cached, ok := cache.Get(hash); ok {
+		*relArgs = cached
+		return nil
+	}
+	if err := prepareRelease(ctx, c, *relArgs, args); err != nil {
+		return err
+	}
+	cache.Put(hash, *relArgs)
+	return nil
+}
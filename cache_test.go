@@ -0,0 +1,160 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// countingCache is a ReleaseConfigCache that counts its hits and misses.
+type countingCache struct {
+	ReleaseConfigCache
+	hits, misses int
+}
+
+func (c *countingCache) Get(hash string) (*ReleaseType, bool) {
+	rel, ok := c.ReleaseConfigCache.Get(hash)
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	return rel, ok
+}
+
+// useCache makes Construct use a fresh counting cache for the rest of the test.
+func useCache(t *testing.T) *countingCache {
+	cache := &countingCache{ReleaseConfigCache: NewMemoryReleaseConfigCache()}
+	old := DefaultReleaseConfigCache
+	DefaultReleaseConfigCache = cache
+	t.Cleanup(func() { DefaultReleaseConfigCache = old })
+	return cache
+}
+
+// configHash returns ReleaseConfigHash for c and args within a mocked program.
+func configHash(t *testing.T, c Chart, args ChartArgs) (string, error) {
+	t.Helper()
+	var hash string
+	var hashErr error
+	err := runMocked(t, &testMocks{}, false, func(ctx *pulumi.Context) error {
+		hash, hashErr = ReleaseConfigHash(ctx, c, args)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash, hashErr
+}
+
+func TestReleaseConfigCacheHit(t *testing.T) {
+	cache := useCache(t)
+	args := func() *testArgs {
+		return &testArgs{ReplicaCount: intPtr(2), Helm: &ReleaseType{Values: map[string]interface{}{"a": "b"}}}
+	}
+	var values []map[string]interface{}
+	for i := 0; i < 2; i++ {
+		mocks := &testMocks{}
+		if _, err := constructMocked(t, mocks, &testChart{}, args()); err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, mocks.releaseValues(t))
+	}
+	if cache.misses != 1 || cache.hits != 1 {
+		t.Errorf("hits = %d, misses = %d, want 1 of each", cache.hits, cache.misses)
+	}
+	want := map[string]interface{}{"a": "b", "replicaCount": 2.0}
+	for i, v := range values {
+		if v["a"] != want["a"] || v["replicaCount"] != want["replicaCount"] {
+			t.Errorf("release %d values = %v, want %v", i, v, want)
+		}
+	}
+}
+
+func TestReleaseConfigHashValueFiles(t *testing.T) {
+	a := writeFile(t, "a.yaml", "extra: 1\n")
+	b := writeFile(t, "b.yaml", "extra: 1\n")
+	hash := func(files ...pulumi.AssetOrArchive) string {
+		h, err := configHash(t, &testChart{}, &testArgs{Helm: &ReleaseType{ValueYamlFiles: files}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	ha, hb := hash(pulumi.NewFileAsset(a)), hash(pulumi.NewFileAsset(b))
+	if ha == hb {
+		t.Error("releases with different value files share a hash")
+	}
+	if again := hash(pulumi.NewFileAsset(a)); again != ha {
+		t.Error("the same value file hashed differently")
+	}
+	if err := ioutil.WriteFile(a, []byte("extra: 2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if changed := hash(pulumi.NewFileAsset(a)); changed == ha {
+		t.Error("changing a value file's contents didn't change the hash")
+	}
+	if hash(pulumi.NewStringAsset("x: 1\n")) == hash(pulumi.NewStringAsset("x: 2\n")) {
+		t.Error("releases with different text value files share a hash")
+	}
+
+	// Through Construct, the second release gets its own file's values, not the first's.
+	useCache(t)
+	for _, tc := range []struct {
+		path string
+		want float64
+	}{{a, 2}, {b, 1}} {
+		mocks := &testMocks{}
+		args := &testArgs{Helm: &ReleaseType{ValueYamlFiles: []pulumi.AssetOrArchive{pulumi.NewFileAsset(tc.path)}}}
+		if _, err := constructMocked(t, mocks, &testChart{}, args); err != nil {
+			t.Fatal(err)
+		}
+		if got := mocks.releaseValues(t)["extra"]; got != tc.want {
+			t.Errorf("%s: extra = %v, want %v", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestReleaseConfigHashUncacheable(t *testing.T) {
+	for name, tc := range map[string]struct {
+		c    Chart
+		args *testArgs
+	}{
+		"renderer":       {&renderingChart{}, &testArgs{}},
+		"repo index":     {&testChart{}, &testArgs{Helm: &ReleaseType{ValidateRepoIndex: boolPtr(true)}}},
+		"existing CRDs":  {&testChart{}, &testArgs{Helm: &ReleaseType{SkipExistingCrds: boolPtr(true)}}},
+		"archive values": {&testChart{}, &testArgs{Helm: &ReleaseType{ValueYamlFiles: []pulumi.AssetOrArchive{pulumi.NewFileArchive(".")}}}},
+	} {
+		if _, err := configHash(t, tc.c, tc.args); errors.Cause(err) != ErrUncacheableRelease {
+			t.Errorf("%s: err = %v, want ErrUncacheableRelease", name, err)
+		}
+	}
+
+	// Uncacheable releases are still prepared, just never cached.
+	cache := useCache(t)
+	c := &renderingChart{rendered: map[string]interface{}{"a": "b"}}
+	for i := 0; i < 2; i++ {
+		if _, err := constructMocked(t, &testMocks{}, c, &testArgs{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cache.hits != 0 || cache.misses != 0 {
+		t.Errorf("hits = %d, misses = %d, want the cache unused", cache.hits, cache.misses)
+	}
+}
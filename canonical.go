@@ -0,0 +1,108 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// CanonicalValues returns a canonical copy of values, for drift tools that compare them
+// across runs: values that mean the same thing to Helm come out identical. Every map
+// becomes a map[string]interface{}, whose keys encoding/json and YAML encoders then emit
+// in sorted order; every list becomes an []interface{}; integral numbers, whatever their
+// Go type, become int64 (so 3, int32(3), and 3.0 agree), and other numbers float64.
+// Values with no JSON equivalent, such as functions or infinite numbers, are an error.
+func CanonicalValues(values map[string]interface{}) (map[string]interface{}, error) {
+	res, err := canonicalValue(reflect.ValueOf(values), "")
+	if err != nil {
+		return nil, err
+	}
+	m, _ := res.(map[string]interface{})
+	return m, nil
+}
+
+func canonicalValue(v reflect.Value, path string) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return canonicalValue(v.Elem(), path)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		res := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k := fmt.Sprint(iter.Key().Interface())
+			e, err := canonicalValue(iter.Value(), joinValuePath(path, k))
+			if err != nil {
+				return nil, err
+			}
+			res[k] = e
+		}
+		return res, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		res := make([]interface{}, v.Len())
+		for i := range res {
+			e, err := canonicalValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			res[i] = e
+		}
+		return res, nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := v.Uint(); u <= math.MaxInt64 {
+			return int64(u), nil
+		}
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, errors.Errorf("value at %q is %v, which has no canonical form", path, f)
+		}
+		if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return int64(f), nil
+		}
+		return f, nil
+	default:
+		return nil, errors.Errorf("value at %q has unsupported type %v", path, v.Type())
+	}
+}
+
+func joinValuePath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,88 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestCanonicalValuesEqualMaps(t *testing.T) {
+	// The same values, as a YAML decoder, a JSON decoder, and Go code might produce them.
+	var fromYAML map[string]interface{}
+	if err := yaml.Unmarshal([]byte("replicas: 3\nratio: 0.5\nimage:\n  tag: v1\nports: [80, 443]\n"),
+		&fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"ports":[80,443],"image":{"tag":"v1"},"ratio":0.5,"replicas":3.0}`),
+		&fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	tag := "v1"
+	fromGo := map[string]interface{}{
+		"replicas": int32(3),
+		"ratio":    float32(0.5),
+		"image":    map[string]*string{"tag": &tag},
+		"ports":    []uint16{80, 443},
+	}
+
+	want := map[string]interface{}{
+		"replicas": int64(3),
+		"ratio":    0.5,
+		"image":    map[string]interface{}{"tag": "v1"},
+		"ports":    []interface{}{int64(80), int64(443)},
+	}
+	var encoded []string
+	for name, values := range map[string]map[string]interface{}{"yaml": fromYAML, "json": fromJSON, "go": fromGo} {
+		got, err := CanonicalValues(values)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: CanonicalValues = %#v, want %#v", name, got, want)
+		}
+		data, err := json.Marshal(got)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		encoded = append(encoded, string(data))
+	}
+	for _, e := range encoded[1:] {
+		if e != encoded[0] {
+			t.Errorf("encodings differ: %v", encoded)
+			break
+		}
+	}
+}
+
+func TestCanonicalValuesUnsupported(t *testing.T) {
+	for _, tc := range []struct {
+		values map[string]interface{}
+		err    string
+	}{
+		{map[string]interface{}{"a": map[string]interface{}{"b": math.Inf(1)}}, `value at "a.b" is +Inf`},
+		{map[string]interface{}{"hooks": []interface{}{func() {}}}, `value at "hooks[0]" has unsupported type func()`},
+	} {
+		if _, err := CanonicalValues(tc.values); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("err = %v, want one containing %q", err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	"gopkg.in/yaml.v2"
+)
+
+// ChartMetadata is the subset of a chart's `Chart.yaml` that helmbase cares about.
+type ChartMetadata struct {
+	Name         string            `yaml:"name"`
+	Version      string            `yaml:"version"`
+	AppVersion   string            `yaml:"appVersion"`
+	Dependencies []ChartDependency `yaml:"dependencies"`
+}
+
+// ChartDependency is a sub-chart dependency declared in `Chart.yaml`.
+type ChartDependency struct {
+	Name       string `yaml:"name"`
+	Version    string `yaml:"version"`
+	Repository string `yaml:"repository"`
+}
+
+// IsLocalChart reports whether the chart reference is a path to a chart directory on disk.
+func IsLocalChart(chart string) bool {
+	_, err := os.Stat(filepath.Join(chart, "Chart.yaml"))
+	return err == nil
+}
+
+// LoadChartMetadata reads and parses the `Chart.yaml` within the given chart directory.
+func LoadChartMetadata(dir string) (*ChartMetadata, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "Chart.yaml"))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading chart metadata")
+	}
+	var meta ChartMetadata
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", filepath.Join(dir, "Chart.yaml"))
+	}
+	return &meta, nil
+}
+
+// CheckDependencies verifies that every dependency declared by the local chart in dir can
+// be resolved: `file://` dependencies must point at a chart directory, and those from an
+// HTTP repository must have a version in its index satisfying the declared constraint.
+// Dependencies from OCI registries or named repository aliases can't be checked up front,
+// and are skipped. Indexes are fetched with the credentials and TLS files in creds, such as
+// the release's RepositoryOpts, for dependencies served from the same repository.
+func CheckDependencies(dir string, creds helmv3.RepositoryOpts) error {
+	meta, err := LoadChartMetadata(dir)
+	if err != nil {
+		return err
+	}
+	for _, dep := range meta.Dependencies {
+		if err := checkDependency(dir, dep, creds); err != nil {
+			return errors.Wrapf(err, "dependency %q", dep.Name)
+		}
+	}
+	return nil
+}
+
+func checkDependency(dir string, dep ChartDependency, creds helmv3.RepositoryOpts) error {
+	repo := dep.Repository
+	switch {
+	case repo == "":
+		// Without a repository, the dependency must already be vendored into charts/.
+		if !IsLocalChart(filepath.Join(dir, "charts", dep.Name)) {
+			return errors.Errorf("no repository given and not found in %s", filepath.Join(dir, "charts"))
+		}
+		return nil
+	case strings.HasPrefix(repo, "file://"):
+		path := strings.TrimPrefix(repo, "file://")
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if !IsLocalChart(path) {
+			return errors.Errorf("%s is not a chart directory", path)
+		}
+		return nil
+	case strings.HasPrefix(repo, "http://"), strings.HasPrefix(repo, "https://"):
+		opts := helmv3.RepositoryOpts{Repo: &repo}
+		if sameRepo(creds.Repo, repo) {
+			opts = creds
+			opts.Repo = &repo
+		}
+		idx, err := FetchRepoIndex(opts)
+		if err != nil {
+			return err
+		}
+		_, err = idx.Resolve(dep.Name, dep.Version)
+		return err
+	default:
+		return nil
+	}
+}
+
+// sameRepo reports whether the repository URL in a refers to the repository at b.
+func sameRepo(a *string, b string) bool {
+	if a == nil {
+		return false
+	}
+	na, erra := NormalizeRepoURL(*a)
+	nb, errb := NormalizeRepoURL(b)
+	return erra == nil && errb == nil && na == nb
+}
+
+// FromChartDir builds a ReleaseType for the local chart in dir, versioned after its
+// `Chart.yaml`, with Values read from the file at valuesPath. An empty valuesPath means
+// the chart's own `values.yaml`, if it has one. This is a quick way to adopt an existing
+// chart directory and values file. The release Name is left unset, so that installing the
+// same chart twice doesn't collide; set it to pin the release's name.
+func FromChartDir(dir, valuesPath string) (*ReleaseType, error) {
+	meta, err := LoadChartMetadata(dir)
+	if err != nil {
+		return nil, err
+	}
+	rel := &ReleaseType{Chart: dir}
+	if meta.Version != "" {
+		rel.Version = &meta.Version
+	}
+
+	if valuesPath == "" {
+		valuesPath = filepath.Join(dir, "values.yaml")
+		if _, err := os.Stat(valuesPath); os.IsNotExist(err) {
+			return rel, nil
+		}
+	}
+	if rel.Values, err = LoadValuesFile(valuesPath); err != nil {
+		return nil, err
+	}
+	return rel, nil
+}
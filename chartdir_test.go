@@ -0,0 +1,126 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+)
+
+// writeChart writes a chart directory at dir with the given Chart.yaml, returning dir.
+func writeChart(t *testing.T, dir, chartYAML string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte(chartYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestCheckDependencies(t *testing.T) {
+	srv := serveRepoIndex(t, testRepoIndex, "", "")
+	root := t.TempDir()
+	writeChart(t, filepath.Join(root, "common"), "name: common\nversion: 0.1.0\n")
+	writeChart(t, filepath.Join(root, "app", "charts", "vendored"), "name: vendored\nversion: 0.1.0\n")
+
+	for _, tc := range []struct {
+		name, deps, err string
+	}{
+		{"none", "", ""},
+		{"file", "- name: common\n  repository: file://../common\n", ""},
+		{"vendored", "- name: vendored\n", ""},
+		{"repo", "- name: nginx\n  version: ~1.2\n  repository: " + srv.URL + "\n", ""},
+		{"oci", "- name: redis\n  repository: oci://registry.example.com/charts\n", ""},
+		{"missing file", "- name: gone\n  repository: file://../gone\n", `dependency "gone"`},
+		{"not vendored", "- name: absent\n", `dependency "absent": no repository given`},
+		{"missing version", "- name: nginx\n  version: ^2.0.0\n  repository: " + srv.URL + "\n",
+			`no version of chart "nginx" in repo index satisfies "^2.0.0"`},
+		{"missing chart", "- name: redis\n  repository: " + srv.URL + "\n", `chart "redis" not found`},
+	} {
+		dir := writeChart(t, filepath.Join(root, "app"), "name: app\nversion: 1.0.0\ndependencies:\n"+tc.deps)
+		err := CheckDependencies(dir, helmv3.RepositoryOpts{})
+		if tc.err == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		} else if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%s: err = %v, want %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestCheckDependenciesUsesCredentials(t *testing.T) {
+	srv := serveRepoIndex(t, testRepoIndex, "user", "pass")
+	dir := writeChart(t, t.TempDir(), "name: app\ndependencies:\n- name: nginx\n  repository: "+srv.URL+"/\n")
+
+	if err := CheckDependencies(dir, helmv3.RepositoryOpts{}); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("without credentials: err = %v, want 401", err)
+	}
+	creds := helmv3.RepositoryOpts{Repo: strPtr(srv.URL), Username: strPtr("user"), Password: strPtr("pass")}
+	if err := CheckDependencies(dir, creds); err != nil {
+		t.Errorf("with the release's credentials: %v", err)
+	}
+
+	// Credentials for another repository aren't sent.
+	other := helmv3.RepositoryOpts{Repo: strPtr("https://other.example.com"), Username: strPtr("user"),
+		Password: strPtr("pass")}
+	if err := CheckDependencies(dir, other); err == nil {
+		t.Error("expected credentials for another repository to be withheld")
+	}
+}
+
+func TestFromChartDir(t *testing.T) {
+	dir := writeChart(t, filepath.Join(t.TempDir(), "nginx"), "apiVersion: v2\nname: nginx\nversion: 1.2.5\n")
+	if err := ioutil.WriteFile(filepath.Join(dir, "values.yaml"), []byte("replicaCount: 2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	override := writeFile(t, "prod.yaml", "replicaCount: 5\n")
+
+	for _, tc := range []struct {
+		name, values string
+		replicas     interface{}
+	}{
+		{"chart values", "", 2},
+		{"values file", override, 5},
+	} {
+		rel, err := FromChartDir(dir, tc.values)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if rel.Chart != dir || rel.Version == nil || *rel.Version != "1.2.5" {
+			t.Errorf("%s: chart = %q, version = %v, want %q and 1.2.5", tc.name, rel.Chart, rel.Version, dir)
+		}
+		if rel.Name != nil {
+			t.Errorf("%s: release name = %q, want unset", tc.name, *rel.Name)
+		}
+		if got := rel.Values["replicaCount"]; got != tc.replicas {
+			t.Errorf("%s: replicaCount = %v, want %v", tc.name, got, tc.replicas)
+		}
+	}
+
+	// A chart without its own values.yaml has no values, and a missing chart is an error.
+	bare := writeChart(t, filepath.Join(t.TempDir(), "bare"), "apiVersion: v2\nname: bare\nversion: 0.1.0\n")
+	if rel, err := FromChartDir(bare, ""); err != nil || rel.Values != nil {
+		t.Errorf("bare chart: rel = %+v, err = %v, want no values", rel, err)
+	}
+	if _, err := FromChartDir(filepath.Join(t.TempDir(), "missing"), ""); err == nil {
+		t.Error("missing chart: expected an error")
+	}
+}
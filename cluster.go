@@ -0,0 +1,158 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// ClusterTarget identifies the cluster a release is installed into, in the terms the
+// Kubernetes provider is configured with: a kubeconfig, either a path or its contents, and
+// a context within it. Empty fields fall back to the ambient kubeconfig and its current
+// context, as they do for the provider.
+type ClusterTarget struct {
+	Kubeconfig string
+	Context    string
+}
+
+// ClusterTargeter may optionally be implemented by a Chart that supplies its own provider
+// (see ReleaseProviderer), to say which cluster that provider targets, so that cluster
+// lookups check the same cluster the release is installed into.
+type ClusterTargeter interface {
+	ClusterTarget() ClusterTarget
+}
+
+// ClusterTargetFor returns the cluster the chart's releases are installed into: the
+// chart's own target, if it implements ClusterTargeter, or else the default Kubernetes
+// provider's, as configured by the stack's `kubernetes:kubeconfig` and `kubernetes:context`.
+func ClusterTargetFor(ctx *pulumi.Context, c Chart) ClusterTarget {
+	if t, ok := c.(ClusterTargeter); ok {
+		return t.ClusterTarget()
+	}
+	return ClusterTarget{Kubeconfig: config.Get(ctx, "kubernetes:kubeconfig"), Context: config.Get(ctx, "kubernetes:context")}
+}
+
+// ClusterObject describes an object found by LookupClusterObject or ListClusterObjects.
+type ClusterObject struct {
+	Name   string
+	Labels map[string]string
+}
+
+// LookupClusterObject looks up the cluster-scoped object of the given kind, e.g. `crd` or
+// `namespace`, in the target cluster, returning nil if there is no such object. Unlike
+// reading the object as a Pulumi resource, a missing object isn't an error. The default
+// asks `kubectl`, found on the PATH; it may be replaced, for instance to use a different
+// client or a fake in tests.
+var LookupClusterObject = kubectlLookup
+
+// ListClusterObjects lists the objects of the given kind, e.g. `secret`, in a namespace
+// of the target cluster whose labels match the selector, e.g. `owner=helm,name=web`. Like
+// LookupClusterObject, the default asks `kubectl` and may be replaced.
+var ListClusterObjects = kubectlList
+
+// DeleteClusterObject deletes the named object of the given kind from a namespace of the
+// target cluster. An object that is already gone isn't an error. Like LookupClusterObject,
+// the default asks `kubectl` and may be replaced.
+var DeleteClusterObject = kubectlDelete
+
+// kubectlObject is the part of an object, as `kubectl -o json` prints it, that helmbase reads.
+type kubectlObject struct {
+	Metadata struct {
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels"`
+	} `json:"metadata"`
+}
+
+func (o kubectlObject) clusterObject() *ClusterObject {
+	return &ClusterObject{Name: o.Metadata.Name, Labels: o.Metadata.Labels}
+}
+
+func kubectlLookup(target ClusterTarget, kind, name string) (*ClusterObject, error) {
+	out, err := kubectl(target, "get", kind, name, "--ignore-not-found", "-o", "json")
+	if err != nil {
+		return nil, errors.Wrapf(err, "looking up %s %q", kind, name)
+	}
+	if len(strings.TrimSpace(string(out))) == 0 {
+		return nil, nil
+	}
+	var obj kubectlObject
+	if err := json.Unmarshal(out, &obj); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s %q", kind, name)
+	}
+	return obj.clusterObject(), nil
+}
+
+func kubectlList(target ClusterTarget, kind, namespace, selector string) ([]ClusterObject, error) {
+	out, err := kubectl(target, "get", kind, "--namespace", namespace, "--selector", selector, "-o", "json")
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing %s matching %q", kind, selector)
+	}
+	var list struct {
+		Items []kubectlObject `json:"items"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s matching %q", kind, selector)
+	}
+	res := make([]ClusterObject, len(list.Items))
+	for i, item := range list.Items {
+		res[i] = *item.clusterObject()
+	}
+	return res, nil
+}
+
+func kubectlDelete(target ClusterTarget, kind, namespace, name string) error {
+	_, err := kubectl(target, "delete", kind, name, "--namespace", namespace, "--ignore-not-found")
+	return errors.Wrapf(err, "deleting %s %q", kind, name)
+}
+
+// kubectl runs `kubectl` against the target cluster with the given arguments, returning
+// what it prints.
+func kubectl(target ClusterTarget, args ...string) ([]byte, error) {
+	bin, err := exec.LookPath("kubectl")
+	if err != nil {
+		return nil, errors.Wrap(err, "locating kubectl")
+	}
+	if kubeconfig := target.Kubeconfig; kubeconfig != "" {
+		// Like the provider, accept the kubeconfig's contents as well as its path.
+		if strings.Contains(kubeconfig, "\n") {
+			f, err := ioutil.TempFile("", "helmbase-kubeconfig-")
+			if err != nil {
+				return nil, errors.Wrap(err, "writing kubeconfig")
+			}
+			defer os.Remove(f.Name())
+			_, err = f.WriteString(kubeconfig)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return nil, errors.Wrap(err, "writing kubeconfig")
+			}
+			kubeconfig = f.Name()
+		}
+		args = append(args, "--kubeconfig", kubeconfig)
+	}
+	if target.Context != "" {
+		args = append(args, "--context", target.Context)
+	}
+	return exec.Command(bin, args...).Output()
+}
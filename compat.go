@@ -0,0 +1,83 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+)
+
+// ReleaseMinProviderVersion is the first pulumi-kubernetes provider version to offer the
+// Helm Release resource, and thus the minimum for any of its fields.
+const ReleaseMinProviderVersion = "3.9.0"
+
+// MinProviderVersions records, by `pulumi:"x"` name, the minimum pulumi-kubernetes
+// provider version required for Release fields introduced after the resource itself.
+// Fields not listed here only require ReleaseMinProviderVersion.
+var MinProviderVersions = map[string]string{
+	// Accepted by the schema from the start, but ignored by the provider until v3.16.0.
+	"valueYamlFiles": "3.16.0",
+}
+
+// CheckProviderCompatibility returns a warning for each Helm option set on the release
+// that requires a newer pulumi-kubernetes provider than the given version.
+func CheckProviderCompatibility(args *ReleaseType, providerVersion string) ([]string, error) {
+	target, err := semver.ParseTolerant(providerVersion)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing provider version %q", providerVersion)
+	}
+
+	var warnings []string
+	for _, name := range usedReleaseFields(args) {
+		min := ReleaseMinProviderVersion
+		if v, ok := MinProviderVersions[name]; ok {
+			min = v
+		}
+		required, err := semver.ParseTolerant(min)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing minimum provider version for %s", name)
+		}
+		if target.LT(required) {
+			warnings = append(warnings, fmt.Sprintf(
+				"`%s` requires pulumi-kubernetes v%s or newer, but the release targets v%s",
+				name, required, target))
+		}
+	}
+	return warnings, nil
+}
+
+// usedReleaseFields returns the sorted `pulumi:"x"` names of the fields set on the release
+// that are passed through to the Helm Release, excluding helmbase's own extensions.
+func usedReleaseFields(args *ReleaseType) []string {
+	helmArgs := reflect.TypeOf(helmv3.ReleaseArgs{})
+	v := reflect.ValueOf(args).Elem()
+
+	var names []string
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if _, ok := helmArgs.FieldByName(f.Name); !ok || releaseOutputOnlyFields[f.Name] || v.Field(i).IsZero() {
+			continue
+		}
+		names = append(names, strings.Split(f.Tag.Get("pulumi"), ",")[0])
+	}
+	sort.Strings(names)
+	return names
+}
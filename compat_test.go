@@ -0,0 +1,52 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func TestCheckProviderCompatibility(t *testing.T) {
+	files := &ReleaseType{Chart: "nginx", ValueYamlFiles: []pulumi.AssetOrArchive{pulumi.NewStringAsset("a: b")}}
+	for _, tc := range []struct {
+		args     *ReleaseType
+		version  string
+		warnings []string
+	}{
+		{files, "3.15.0", []string{"`valueYamlFiles` requires pulumi-kubernetes v3.16.0 or newer, but the release targets v3.15.0"}},
+		{files, "v3.16.0", nil},
+		{&ReleaseType{Chart: "nginx"}, "3.15.0", nil},
+		{&ReleaseType{Chart: "nginx"}, "3.8.1", []string{"`chart` requires pulumi-kubernetes v3.9.0 or newer, but the release targets v3.8.1"}},
+	} {
+		warnings, err := CheckProviderCompatibility(tc.args, tc.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(warnings) != len(tc.warnings) {
+			t.Errorf("%s: warnings = %v, want %v", tc.version, warnings, tc.warnings)
+			continue
+		}
+		for i := range warnings {
+			if warnings[i] != tc.warnings[i] {
+				t.Errorf("%s: warning = %q, want %q", tc.version, warnings[i], tc.warnings[i])
+			}
+		}
+	}
+	if _, err := CheckProviderCompatibility(files, "latest"); err == nil {
+		t.Error("expected an error for an unparsable provider version")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// CRDLister may optionally be implemented by a Chart to list the names of the
+// CustomResourceDefinitions it installs, e.g. `certificates.cert-manager.io`. It enables
+// SkipExistingCrds.
+type CRDLister interface {
+	CRDs() []string
+}
+
+// ApplySkipExistingCRDs sets SkipCrds when every CRD the chart installs already exists in
+// the cluster the chart targets (see ClusterTargetFor), since installing them again can
+// fail. An explicit SkipCrds always wins, and charts that don't implement CRDLister, or
+// list no CRDs, are left alone. If the CRDs can't be looked up, for instance because the
+// cluster is created by the same program, SkipCrds is left unset too.
+//
+// The decision only matters when the release is installed, since Helm never touches CRDs
+// on upgrade. So that the CRDs appearing after the first install doesn't register as a
+// change to the Release, `skipCrds` is added to its ignoreChanges.
+func ApplySkipExistingCRDs(ctx *pulumi.Context, args *ReleaseType, c Chart) {
+	l, ok := c.(CRDLister)
+	if !ok || args.SkipCrds != nil || len(l.CRDs()) == 0 {
+		return
+	}
+	ignoreChanges(args, "skipCrds")
+	target := ClusterTargetFor(ctx, c)
+	for _, name := range l.CRDs() {
+		obj, err := LookupClusterObject(target, "crd", name)
+		if err != nil || obj == nil {
+			return
+		}
+	}
+	t := true
+	args.SkipCrds = &t
+}
+
+// ignoreChanges adds the given Release input property to the release's ignoreChanges. The
+// ResourceOptions are copied first, since the caller may share them between releases.
+func ignoreChanges(args *ReleaseType, prop string) {
+	opts := ReleaseResourceOptions{}
+	if args.ResourceOptions != nil {
+		opts = *args.ResourceOptions
+	}
+	for _, p := range opts.IgnoreChanges {
+		if p == prop {
+			return
+		}
+	}
+	opts.IgnoreChanges = append(append([]string(nil), opts.IgnoreChanges...), prop)
+	args.ResourceOptions = &opts
+}
+
+// CheckRequiredCRDs verifies that every CRD in the release's RequiredCRDs already exists
+// in the cluster the chart targets (see ClusterTargetFor), for charts that rely on CRDs
+// installed by something else, such as cert-manager's. Missing CRDs fail the update, but
+// during previews they're only warned about, since they may be installed by the same
+// program. If the CRDs can't be looked up at all, for instance because the cluster is
+// created by the same program, the check is skipped with a warning.
+func CheckRequiredCRDs(ctx *pulumi.Context, log Logger, c Chart, args *ReleaseType) error {
+	if len(args.RequiredCRDs) == 0 {
+		return nil
+	}
+	target := ClusterTargetFor(ctx, c)
+	var missing []string
+	for _, name := range args.RequiredCRDs {
+		obj, err := LookupClusterObject(target, "crd", name)
+		if err != nil {
+			return log.Warn(fmt.Sprintf("skipping the required CRD check: %v", err))
+		}
+		if obj == nil {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("required CRDs are not installed in the cluster: %s; install them first, "+
+		"for example by deploying the chart that provides them and making this release depend on it",
+		strings.Join(missing, ", "))
+	if ctx.DryRun() {
+		return log.Warn(msg)
+	}
+	return errors.New(msg)
+}
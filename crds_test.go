@@ -0,0 +1,186 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// crdChart is a chart that installs CRDs.
+type crdChart struct {
+	pulumi.ResourceState
+	chartBase
+}
+
+func (c *crdChart) CRDs() []string {
+	return []string{"certificates.cert-manager.io", "issuers.cert-manager.io"}
+}
+
+func TestApplySkipExistingCRDs(t *testing.T) {
+	both := map[string]*ClusterObject{
+		"crd/certificates.cert-manager.io": {},
+		"crd/issuers.cert-manager.io":      {},
+	}
+	one := map[string]*ClusterObject{"crd/certificates.cert-manager.io": {}}
+	for _, tc := range []struct {
+		name     string
+		objects  map[string]*ClusterObject
+		skipCrds *bool
+		want     interface{}
+		ignored  bool
+	}{
+		{"all present", both, nil, true, true},
+		{"one missing", one, nil, nil, true},
+		{"explicit", both, boolPtr(false), false, false},
+	} {
+		fakeCluster(t, tc.objects)
+		mocks := &testMocks{}
+		args := &testArgs{Helm: &ReleaseType{SkipExistingCrds: boolPtr(true), SkipCrds: tc.skipCrds}}
+		if _, err := constructMocked(t, mocks, &crdChart{}, args); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		rel := mocks.release(t)
+		var got interface{}
+		if v, ok := rel.Inputs["skipCrds"]; ok {
+			got = v.BoolValue()
+		}
+		if got != tc.want {
+			t.Errorf("%s: skipCrds = %v, want %v", tc.name, got, tc.want)
+		}
+		ignored := reflect.DeepEqual(rel.RegisterRPC.GetIgnoreChanges(), []string{"skipCrds"})
+		if ignored != tc.ignored {
+			t.Errorf("%s: ignoreChanges = %v, want skipCrds ignored: %v", tc.name, rel.RegisterRPC.GetIgnoreChanges(), tc.ignored)
+		}
+	}
+}
+
+func TestApplySkipExistingCRDsSharedOptions(t *testing.T) {
+	fakeCluster(t, nil)
+	ignored := make([]string, 1, 2)
+	ignored[0] = "values.replicaCount"
+	shared := &ReleaseResourceOptions{IgnoreChanges: ignored}
+	err := runMocked(t, &testMocks{}, false, func(ctx *pulumi.Context) error {
+		for i := 0; i < 2; i++ {
+			args := &ReleaseType{ResourceOptions: shared}
+			ApplySkipExistingCRDs(ctx, args, &crdChart{})
+			want := []string{"values.replicaCount", "skipCrds"}
+			if !reflect.DeepEqual(args.ResourceOptions.IgnoreChanges, want) {
+				t.Errorf("ignoreChanges = %v, want %v", args.ResourceOptions.IgnoreChanges, want)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"values.replicaCount"}; !reflect.DeepEqual(shared.IgnoreChanges, want) {
+		t.Errorf("shared ignoreChanges = %v, want %v", shared.IgnoreChanges, want)
+	}
+	if ignored[:2][1] != "" {
+		t.Errorf("shared ignoreChanges' backing array was written: %v", ignored[:2])
+	}
+}
+
+func TestApplySkipExistingCRDsLookupFails(t *testing.T) {
+	old := LookupClusterObject
+	LookupClusterObject = func(ClusterTarget, string, string) (*ClusterObject, error) {
+		return nil, errors.New("kubectl not found")
+	}
+	defer func() { LookupClusterObject = old }()
+
+	mocks := &testMocks{}
+	args := &testArgs{Helm: &ReleaseType{SkipExistingCrds: boolPtr(true)}}
+	if _, err := constructMocked(t, mocks, &crdChart{}, args); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := mocks.release(t).Inputs["skipCrds"]; ok {
+		t.Errorf("skipCrds = %v, want it left unset", v)
+	}
+}
+
+func TestCheckRequiredCRDs(t *testing.T) {
+	const missingErr = "required CRDs are not installed in the cluster: issuers.cert-manager.io"
+	fakeCluster(t, map[string]*ClusterObject{"crd/certificates.cert-manager.io": {}})
+	for _, tc := range []struct {
+		name   string
+		crds   []string
+		dryRun bool
+		err    bool
+		warn   bool
+	}{
+		{"none", nil, false, false, false},
+		{"present", []string{"certificates.cert-manager.io"}, false, false, false},
+		{"missing", []string{"certificates.cert-manager.io", "issuers.cert-manager.io"}, false, true, false},
+		{"missing in preview", []string{"issuers.cert-manager.io"}, true, false, true},
+	} {
+		log := &recordingLogger{}
+		var cerr error
+		err := runMocked(t, &testMocks{}, tc.dryRun, func(ctx *pulumi.Context) error {
+			cerr = CheckRequiredCRDs(ctx, log, &testChart{}, &ReleaseType{RequiredCRDs: tc.crds})
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.err != (cerr != nil) || (cerr != nil && !strings.Contains(cerr.Error(), missingErr)) {
+			t.Errorf("%s: err = %v, want error: %v", tc.name, cerr, tc.err)
+		}
+		if tc.warn != hasWarning(log.warns, missingErr) {
+			t.Errorf("%s: warnings = %v, want missing CRD warning: %v", tc.name, log.warns, tc.warn)
+		}
+	}
+
+	// A missing CRD fails the construction before the release is created.
+	mocks := &testMocks{}
+	args := &testArgs{Helm: &ReleaseType{RequiredCRDs: []string{"issuers.cert-manager.io"}}}
+	if _, err := constructMocked(t, mocks, &testChart{}, args); err == nil || !strings.Contains(err.Error(), missingErr) {
+		t.Errorf("construct err = %v, want a missing CRD error", err)
+	}
+	if rels := mocks.byType(testReleaseType); len(rels) != 0 {
+		t.Error("a release was created despite the missing CRD")
+	}
+}
+
+func TestCheckRequiredCRDsLookupFails(t *testing.T) {
+	old := LookupClusterObject
+	var targets []ClusterTarget
+	LookupClusterObject = func(target ClusterTarget, kind, name string) (*ClusterObject, error) {
+		targets = append(targets, target)
+		return nil, errors.New("kubectl not found")
+	}
+	defer func() { LookupClusterObject = old }()
+
+	log := &recordingLogger{}
+	c := &targetedChart{}
+	var cerr error
+	_ = runMocked(t, &testMocks{}, false, func(ctx *pulumi.Context) error {
+		cerr = CheckRequiredCRDs(ctx, log, c, &ReleaseType{RequiredCRDs: []string{"issuers.cert-manager.io"}})
+		return nil
+	})
+	if cerr != nil {
+		t.Errorf("err = %v, want the check skipped", cerr)
+	}
+	if !hasWarning(log.warns, "skipping the required CRD check: kubectl not found") {
+		t.Errorf("warnings = %v, want the check reported as skipped", log.warns)
+	}
+	if len(targets) != 1 || targets[0] != c.ClusterTarget() {
+		t.Errorf("looked up in %v, want the chart's target %v", targets, c.ClusterTarget())
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"reflect"
+)
+
+const (
+	// FieldCommonLabelsValue is the conventional chart value holding labels applied to
+	// every resource the chart creates. Charts that don't support it simply ignore it.
+	FieldCommonLabelsValue = "commonLabels"
+	// FieldAffinityValue is the conventional chart value holding pod affinity rules.
+	FieldAffinityValue = "affinity"
+	// FieldTopologySpreadConstraintsValue is the conventional chart value holding pod
+	// topology spread constraints.
+	FieldTopologySpreadConstraintsValue = "topologySpreadConstraints"
+	// FieldNetworkPolicyValue is the conventional chart value configuring NetworkPolicies.
+	FieldNetworkPolicyValue = "networkPolicy"
+	// FieldResourcesValue is the conventional chart value holding container resource
+	// requests and limits.
+	FieldResourcesValue = "resources"
+
+	LabelPulumiProject = "pulumi.com/project"
+	LabelPulumiStack   = "pulumi.com/stack"
+	LabelPulumiChannel = "pulumi.com/channel"
+)
+
+// applyValueDefaults merges defaults underneath the release's Values, so that anything
+// already set, whether by the user or by the strongly typed args, wins.
+func applyValueDefaults(args *ReleaseType, defaults map[string]interface{}) {
+	if args.defaultValues == nil {
+		args.defaultValues = make(map[string]interface{})
+	}
+	walkValueLeaves(defaults, "", func(path string, v interface{}) {
+		args.defaultValues[path] = v
+	})
+	layerValues(args, defaults)
+}
+
+// layerValues merges lower underneath the release's Values, honoring its array merge
+// strategies. Unlike applyValueDefaults, the layered values count as the user's own.
+func layerValues(args *ReleaseType, lower map[string]interface{}) {
+	args.Values = MergeValuesWithStrategies(MergeValues(nil, lower), args.Values, arrayMergeStrategies(args))
+}
+
+// Provenance labels returned by ValueProvenance.
+const (
+	ProvenanceDefault = "default"
+	ProvenanceUser    = "user"
+)
+
+// ValueProvenance reports, for every leaf of the release's Values keyed by dotted path,
+// whether it came from a default (ProvenanceDefault), such as a rendered, conventional, or
+// label default, or from the user (ProvenanceUser), either via Values or the strongly
+// typed args. A default that was overridden with a different value counts as the user's.
+func ValueProvenance(args *ReleaseType) map[string]string {
+	res := make(map[string]string)
+	walkValueLeaves(args.Values, "", func(path string, v interface{}) {
+		if d, ok := args.defaultValues[path]; ok && reflect.DeepEqual(d, v) {
+			res[path] = ProvenanceDefault
+		} else {
+			res[path] = ProvenanceUser
+		}
+	})
+	return res
+}
+
+// walkValueLeaves calls fn for every non-map value nested within values, along with its
+// dotted path. Empty maps are treated as leaves.
+func walkValueLeaves(values map[string]interface{}, prefix string, fn func(path string, v interface{})) {
+	for k, v := range values {
+		if m, ok := v.(map[string]interface{}); ok && len(m) > 0 {
+			walkValueLeaves(m, prefix+k+".", fn)
+			continue
+		}
+		fn(prefix+k, v)
+	}
+}
+
+// arrayMergeStrategies returns the release's per-path array merge strategies.
+func arrayMergeStrategies(args *ReleaseType) map[string]ArrayMergeStrategy {
+	if len(args.ArrayMergeStrategies) == 0 {
+		return nil
+	}
+	res := make(map[string]ArrayMergeStrategy, len(args.ArrayMergeStrategies))
+	for path, s := range args.ArrayMergeStrategies {
+		res[path] = ArrayMergeStrategy(s)
+	}
+	return res
+}
+
+// ApplyStackLabels stamps the originating Pulumi project and stack onto the release's
+// common labels. The Helm Release resource has no labels of its own, so these are passed
+// to the chart using the conventional `commonLabels` value. Labels the user has already
+// set are left alone.
+func ApplyStackLabels(args *ReleaseType, project, stack string) {
+	applyValueDefaults(args, map[string]interface{}{
+		FieldCommonLabelsValue: map[string]interface{}{
+			LabelPulumiProject: project,
+			LabelPulumiStack:   stack,
+		},
+	})
+}
+
+// ApplyChannelLabel stamps the release's Channel, if any, onto its common labels, in the
+// same way as ApplyStackLabels. A label the user has already set is left alone.
+func ApplyChannelLabel(args *ReleaseType) {
+	if args.Channel == nil || *args.Channel == "" {
+		return
+	}
+	applyValueDefaults(args, map[string]interface{}{
+		FieldCommonLabelsValue: map[string]interface{}{LabelPulumiChannel: *args.Channel},
+	})
+}
+
+// ConventionalValuesSupporter may optionally be implemented by a Chart to declare which
+// conventional values (such as `networkPolicy`) it understands. Charts that don't
+// implement it are assumed to support all of them.
+type ConventionalValuesSupporter interface {
+	SupportsValue(key string) bool
+}
+
+// ApplyConventionalDefaults merges the release's convenience fields (Affinity,
+// TopologySpreadConstraints, NetworkPolicy, and DefaultResources) into their conventional values, skipping
+// any the chart doesn't support. Values that are already set take precedence. The chart
+// may be nil, in which case every conventional value is assumed to be supported.
+func ApplyConventionalDefaults(args *ReleaseType, c Chart) {
+	supports := func(string) bool { return true }
+	if s, ok := c.(ConventionalValuesSupporter); ok {
+		supports = s.SupportsValue
+	}
+
+	defaults := make(map[string]interface{})
+	set := func(key string, v interface{}, ok bool) {
+		if ok && supports(key) {
+			defaults[key] = v
+		}
+	}
+	set(FieldAffinityValue, args.Affinity, args.Affinity != nil)
+	set(FieldTopologySpreadConstraintsValue, args.TopologySpreadConstraints, args.TopologySpreadConstraints != nil)
+	set(FieldNetworkPolicyValue, args.NetworkPolicy, args.NetworkPolicy != nil)
+	set(FieldResourcesValue, args.DefaultResources, args.DefaultResources != nil)
+	applyValueDefaults(args, defaults)
+}
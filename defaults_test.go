@@ -0,0 +1,205 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// commonLabels returns the common labels in the release's Values input.
+func commonLabels(t *testing.T, mocks *testMocks) map[string]interface{} {
+	t.Helper()
+	values := mocks.release(t).Inputs["values"]
+	if !values.IsObject() {
+		return nil
+	}
+	labels, ok := values.ObjectValue()[FieldCommonLabelsValue]
+	if !ok {
+		return nil
+	}
+	return labels.Mappable().(map[string]interface{})
+}
+
+func TestStackLabelsOptIn(t *testing.T) {
+	mocks := &testMocks{}
+	if _, err := constructMocked(t, mocks, &testChart{}, &testArgs{}); err != nil {
+		t.Fatal(err)
+	}
+	if labels := commonLabels(t, mocks); labels != nil {
+		t.Errorf("commonLabels = %v, want none by default", labels)
+	}
+
+	mocks = &testMocks{}
+	args := &testArgs{Helm: &ReleaseType{StackLabels: boolPtr(true)}}
+	if _, err := constructMocked(t, mocks, &testChart{}, args); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{LabelPulumiProject: "project", LabelPulumiStack: "stack"}
+	if labels := commonLabels(t, mocks); !reflect.DeepEqual(labels, want) {
+		t.Errorf("commonLabels = %v, want %v", labels, want)
+	}
+}
+
+func TestApplyStackLabelsKeepsUserLabels(t *testing.T) {
+	args := &ReleaseType{Values: map[string]interface{}{
+		FieldCommonLabelsValue: map[string]interface{}{LabelPulumiStack: "mine"},
+	}}
+	ApplyStackLabels(args, "project", "stack")
+	want := map[string]interface{}{LabelPulumiProject: "project", LabelPulumiStack: "mine"}
+	if got := args.Values[FieldCommonLabelsValue]; !reflect.DeepEqual(got, want) {
+		t.Errorf("commonLabels = %v, want %v", got, want)
+	}
+}
+
+// affinityOnlyChart only supports the conventional affinity value.
+type affinityOnlyChart struct {
+	pulumi.ResourceState
+	chartBase
+}
+
+func (c *affinityOnlyChart) SupportsValue(key string) bool { return key == FieldAffinityValue }
+
+func TestApplyConventionalDefaultsAffinityAndSpread(t *testing.T) {
+	affinity := map[string]interface{}{"nodeAffinity": "zone-a"}
+	spread := []interface{}{map[string]interface{}{"maxSkew": 1}}
+
+	args := &ReleaseType{Affinity: affinity, TopologySpreadConstraints: spread}
+	ApplyConventionalDefaults(args, nil)
+	if got := args.Values[FieldAffinityValue]; !reflect.DeepEqual(got, affinity) {
+		t.Errorf("affinity = %v, want %v", got, affinity)
+	}
+	if got := args.Values[FieldTopologySpreadConstraintsValue]; !reflect.DeepEqual(got, spread) {
+		t.Errorf("topologySpreadConstraints = %v, want %v", got, spread)
+	}
+
+	// Values the user already set win over the defaults.
+	mine := []interface{}{map[string]interface{}{"maxSkew": 2}}
+	args = &ReleaseType{TopologySpreadConstraints: spread,
+		Values: map[string]interface{}{FieldTopologySpreadConstraintsValue: mine}}
+	ApplyConventionalDefaults(args, nil)
+	if got := args.Values[FieldTopologySpreadConstraintsValue]; !reflect.DeepEqual(got, mine) {
+		t.Errorf("topologySpreadConstraints = %v, want the user's %v", got, mine)
+	}
+
+	// Charts only get the conventional values they support.
+	args = &ReleaseType{Affinity: affinity, TopologySpreadConstraints: spread}
+	ApplyConventionalDefaults(args, &affinityOnlyChart{})
+	if _, ok := args.Values[FieldTopologySpreadConstraintsValue]; ok {
+		t.Error("unsupported topologySpreadConstraints should be skipped")
+	}
+	if got := args.Values[FieldAffinityValue]; !reflect.DeepEqual(got, affinity) {
+		t.Errorf("affinity = %v, want %v", got, affinity)
+	}
+}
+
+func TestNetworkPolicyDefaults(t *testing.T) {
+	policy := map[string]interface{}{"enabled": true, "allowExternal": false}
+
+	mocks := &testMocks{}
+	args := &testArgs{Helm: &ReleaseType{NetworkPolicy: policy}}
+	if _, err := constructMocked(t, mocks, &testChart{}, args); err != nil {
+		t.Fatal(err)
+	}
+	if got := mocks.releaseValues(t)[FieldNetworkPolicyValue]; !reflect.DeepEqual(got, policy) {
+		t.Errorf("networkPolicy = %v, want %v", got, policy)
+	}
+
+	// Settings in the chart values win, key by key.
+	mocks = &testMocks{}
+	args = &testArgs{Helm: &ReleaseType{NetworkPolicy: policy, Values: map[string]interface{}{
+		FieldNetworkPolicyValue: map[string]interface{}{"enabled": false},
+	}}}
+	if _, err := constructMocked(t, mocks, &testChart{}, args); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"enabled": false, "allowExternal": false}
+	if got := mocks.releaseValues(t)[FieldNetworkPolicyValue]; !reflect.DeepEqual(got, want) {
+		t.Errorf("networkPolicy = %v, want %v", got, want)
+	}
+
+	// Charts without NetworkPolicy support don't get the value at all.
+	mocks = &testMocks{}
+	args = &testArgs{Helm: &ReleaseType{NetworkPolicy: policy}}
+	if _, err := constructMocked(t, mocks, &affinityOnlyChart{}, args); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := mocks.releaseValues(t)[FieldNetworkPolicyValue]; ok {
+		t.Errorf("networkPolicy = %v, want it skipped for an unsupporting chart", got)
+	}
+}
+
+func TestValueProvenance(t *testing.T) {
+	args := &ReleaseType{Values: map[string]interface{}{
+		"image":        map[string]interface{}{"tag": "mine"},
+		"replicaCount": 3,
+	}}
+	applyValueDefaults(args, map[string]interface{}{
+		"image":   map[string]interface{}{"repository": "nginx", "tag": "latest"},
+		"service": map[string]interface{}{"type": "ClusterIP"},
+		// A default the user happens to repeat still came from the default.
+		"replicaCount": 3,
+	})
+	want := map[string]string{
+		"image.repository": ProvenanceDefault,
+		"image.tag":        ProvenanceUser,
+		"service.type":     ProvenanceDefault,
+		"replicaCount":     ProvenanceDefault,
+	}
+	if got := ValueProvenance(args); !reflect.DeepEqual(got, want) {
+		t.Errorf("provenance = %v, want %v", got, want)
+	}
+
+	if got := ValueProvenance(&ReleaseType{Values: map[string]interface{}{"a": 1}}); got["a"] != ProvenanceUser {
+		t.Errorf("provenance without defaults = %v, want user", got)
+	}
+}
+
+func TestDefaultResources(t *testing.T) {
+	resources := map[string]interface{}{
+		"requests": map[string]interface{}{"cpu": "100m", "memory": "128Mi"},
+		"limits":   map[string]interface{}{"memory": "256Mi"},
+	}
+
+	mocks := &testMocks{}
+	args := &testArgs{Helm: &ReleaseType{DefaultResources: resources}}
+	if _, err := constructMocked(t, mocks, &testChart{}, args); err != nil {
+		t.Fatal(err)
+	}
+	if got := mocks.releaseValues(t)[FieldResourcesValue]; !reflect.DeepEqual(got, resources) {
+		t.Errorf("resources = %v, want %v", got, resources)
+	}
+
+	// The chart's values override the defaults, request by request.
+	mocks = &testMocks{}
+	args = &testArgs{Helm: &ReleaseType{DefaultResources: resources, Values: map[string]interface{}{
+		FieldResourcesValue: map[string]interface{}{"requests": map[string]interface{}{"cpu": "1"}},
+	}}}
+	if _, err := constructMocked(t, mocks, &testChart{}, args); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"requests": map[string]interface{}{"cpu": "1", "memory": "128Mi"},
+		"limits":   map[string]interface{}{"memory": "256Mi"},
+	}
+	if got := mocks.releaseValues(t)[FieldResourcesValue]; !reflect.DeepEqual(got, want) {
+		t.Errorf("resources = %v, want %v", got, want)
+	}
+	if got := ValueProvenance(args.Helm)["resources.requests.memory"]; got != ProvenanceDefault {
+		t.Errorf("resources.requests.memory provenance = %q, want default", got)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+)
+
+// ReleaseEvent is the structured event emitted once a chart's release has been
+// successfully constructed and its status is known.
+type ReleaseEvent struct {
+	// Type is the chart's type token.
+	Type string `json:"type"`
+	// Name is the component's resource name.
+	Name string `json:"name"`
+	// ReleaseName is the name of the release in the cluster.
+	ReleaseName string `json:"releaseName,omitempty"`
+	Chart       string `json:"chart,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Revision    int    `json:"revision,omitempty"`
+	// Status is the Helm status of the release, e.g. "deployed".
+	Status string `json:"status"`
+}
+
+// ReleaseEventEmitter, if set, is called with a ReleaseEvent for every chart constructed,
+// once its release status resolves. Previews don't resolve the status, so only updates
+// emit events. It is nil, so no events are emitted, by default.
+var ReleaseEventEmitter func(ev ReleaseEvent)
+
+// JSONEventEmitter returns a ReleaseEventEmitter that writes each event to w as a single
+// line of JSON, e.g. for CI/CD systems watching os.Stdout. Writes are serialized, so it is
+// safe for concurrent use, and write errors are ignored.
+func JSONEventEmitter(w io.Writer) func(ev ReleaseEvent) {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(ev ReleaseEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = enc.Encode(ev)
+	}
+}
+
+// emitReleaseEvent arranges for ReleaseEventEmitter to be called once the release's status
+// resolves.
+func emitReleaseEvent(typ, name string, rel *helmv3.Release) {
+	emit := ReleaseEventEmitter
+	if emit == nil {
+		return
+	}
+	rel.Status.ApplyT(func(st helmv3.ReleaseStatus) helmv3.ReleaseStatus {
+		ev := ReleaseEvent{Type: typ, Name: name, Status: st.Status}
+		for dst, src := range map[*string]*string{
+			&ev.ReleaseName: st.Name,
+			&ev.Chart:       st.Chart,
+			&ev.Version:     st.Version,
+			&ev.Namespace:   st.Namespace,
+		} {
+			if src != nil {
+				*dst = *src
+			}
+		}
+		if st.Revision != nil {
+			ev.Revision = *st.Revision
+		}
+		emit(ev)
+		return st
+	})
+}
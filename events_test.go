@@ -0,0 +1,69 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func TestReleaseEventEmitted(t *testing.T) {
+	var mu sync.Mutex
+	var events []ReleaseEvent
+	defer func(e func(ReleaseEvent)) { ReleaseEventEmitter = e }(ReleaseEventEmitter)
+	ReleaseEventEmitter = func(ev ReleaseEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, ev)
+	}
+
+	mocks := releaseStatusMocks(map[string]interface{}{
+		"namespace": "apps", "chart": "nginx", "version": "1.2.5", "revision": 2,
+	})
+	if _, err := constructMocked(t, mocks, &testChart{}, &testArgs{}); err != nil {
+		t.Fatal(err)
+	}
+	want := ReleaseEvent{Type: testType, Name: "test", ReleaseName: "test-helm-1a2b3c4d",
+		Chart: "nginx", Version: "1.2.5", Namespace: "apps", Revision: 2, Status: "deployed"}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 1 || events[0] != want {
+		t.Errorf("events = %+v, want [%+v]", events, want)
+	}
+}
+
+func TestJSONEventEmitter(t *testing.T) {
+	var buf bytes.Buffer
+	emit := JSONEventEmitter(&buf)
+	emit(ReleaseEvent{Type: testType, Name: "a", Status: "deployed"})
+	emit(ReleaseEvent{Type: testType, Name: "b", Status: "failed", Revision: 3})
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %s", len(lines), buf.String())
+	}
+	var ev ReleaseEvent
+	if err := json.Unmarshal(lines[1], &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Name != "b" || ev.Status != "failed" || ev.Revision != 3 {
+		t.Errorf("second event = %+v", ev)
+	}
+	if want := `{"type":"` + testType + `","name":"a","status":"deployed"}`; string(lines[0]) != want {
+		t.Errorf("first line = %s, want %s", lines[0], want)
+	}
+}
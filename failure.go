@@ -0,0 +1,79 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// FailureMessageTemplater may optionally be implemented by a Chart to add chart-specific
+// guidance to construction errors. The template is a text/template executed against a
+// FailureInfo, e.g. "installing {{.Chart}} failed: {{.Err}}; see https://example.com/faq".
+type FailureMessageTemplater interface {
+	FailureMessageTemplate() string
+}
+
+// FailureInfo is the data available to a failure message template.
+type FailureInfo struct {
+	// Chart is the chart being installed: the one the user set, or else the chart's default.
+	Chart string
+	// Type is the chart's type token.
+	Type string
+	// Err is the underlying construction error.
+	Err error
+}
+
+// formatFailure rewrites err using the chart's failure message template, if it has one.
+// The result still wraps err, so its cause remains available to callers. Should the
+// template itself be broken, err is returned along with a note saying so.
+func formatFailure(c Chart, args ChartArgs, err error) error {
+	t, ok := c.(FailureMessageTemplater)
+	if !ok || t.FailureMessageTemplate() == "" {
+		return err
+	}
+	tmpl, terr := template.New("failure").Parse(t.FailureMessageTemplate())
+	var msg strings.Builder
+	if terr == nil {
+		terr = tmpl.Execute(&msg, FailureInfo{Chart: failureChart(c, args), Type: c.Type(), Err: err})
+	}
+	if terr != nil {
+		return errors.Wrapf(err, "(failure message template is invalid: %v)", terr)
+	}
+	return &failureError{msg: msg.String(), cause: err}
+}
+
+// failureChart returns the chart the user asked for, falling back to the chart's default
+// name when none was set or the args were never decoded.
+func failureChart(c Chart, args ChartArgs) string {
+	if args != nil {
+		if r := *args.R(); r != nil && r.Chart != "" {
+			return r.Chart
+		}
+	}
+	return defaultChartName(c)
+}
+
+// failureError carries a templated message while preserving the original error as its cause.
+type failureError struct {
+	msg   string
+	cause error
+}
+
+func (e *failureError) Error() string { return e.msg }
+func (e *failureError) Cause() error  { return e.cause }
+func (e *failureError) Unwrap() error { return e.cause }
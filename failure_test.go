@@ -0,0 +1,83 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/provider"
+)
+
+// templatedChart rewords its construction errors using a failure message template.
+type templatedChart struct {
+	pulumi.ResourceState
+	chartBase
+	template string
+}
+
+func (c *templatedChart) FailureMessageTemplate() string { return c.template }
+
+// constructFailure constructs c with args, which must fail, and returns the error as
+// Construct returned it, before the program wraps it.
+func constructFailure(t *testing.T, c Chart, args ChartArgs) error {
+	t.Helper()
+	var cerr error
+	_ = runMocked(t, &testMocks{}, false, func(ctx *pulumi.Context) error {
+		_, cerr = Construct(ctx, c, c.Type(), "test", args, provider.ConstructInputs{}, nil)
+		return cerr
+	})
+	if cerr == nil {
+		t.Fatal("expected construction to fail")
+	}
+	return cerr
+}
+
+func TestFormatFailureTemplate(t *testing.T) {
+	const tmpl = "installing {{.Chart}} ({{.Type}}) failed: {{.Err}}; see https://example.com/faq"
+	for _, tc := range []struct {
+		name  string
+		chart string
+		want  string
+	}{
+		{"default chart", "", "nginx"},
+		{"user chart", "oci://registry.example.com/charts/my-nginx", "oci://registry.example.com/charts/my-nginx"},
+	} {
+		c := &templatedChart{template: tmpl}
+		args := &testArgs{Helm: &ReleaseType{Chart: tc.chart, Timeout: intPtr(0)}}
+		err := constructFailure(t, c, args)
+
+		cause := validatePositiveInts(&ReleaseType{Timeout: intPtr(0)})
+		want := "installing " + tc.want + " (" + testType + ") failed: " + cause.Error() +
+			"; see https://example.com/faq"
+		if err.Error() != want {
+			t.Errorf("%s: err = %q, want %q", tc.name, err, want)
+		}
+		if got := errors.Cause(err); got == err || got.Error() != cause.Error() {
+			t.Errorf("%s: cause = %v, want the underlying error", tc.name, got)
+		}
+	}
+}
+
+func TestFormatFailureInvalidTemplate(t *testing.T) {
+	c := &templatedChart{template: "installing {{.Chart"}
+	err := constructFailure(t, c, &testArgs{Helm: &ReleaseType{Timeout: intPtr(0)}})
+	want := "(failure message template is invalid: "
+	if got := err.Error(); !strings.HasPrefix(got, want) {
+		t.Errorf("err = %q, want it to note the invalid template", got)
+	}
+}
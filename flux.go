@@ -0,0 +1,141 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// FluxHelmReleaseAPIVersion is the Flux API version of the HelmRelease manifests
+	// produced by ToFluxHelmRelease.
+	FluxHelmReleaseAPIVersion = "helm.toolkit.fluxcd.io/v2beta1"
+	// FluxDefaultInterval is the reconciliation interval of the HelmRelease manifests
+	// produced by ToFluxHelmRelease. Flux requires one, and Helm has no equivalent.
+	FluxDefaultInterval = "5m"
+)
+
+// ToFluxHelmRelease translates the release into an equivalent Flux HelmRelease manifest,
+// for teams moving a release between Pulumi and Flux. The manifest's source is a
+// HelmRepository named after the repository URL (see FluxRepositoryName), which must be
+// created separately; local charts have no such source, and are rejected.
+//
+// Only options with a direct Flux equivalent are translated. Anything else, including
+// repository credentials, value files, verification, post-rendering, and helmbase's own
+// extension fields, is dropped, so the result should be reviewed before it's applied.
+func ToFluxHelmRelease(args *ReleaseType) ([]byte, error) {
+	if args.Chart == "" {
+		return nil, errors.New("release has no chart")
+	}
+	if IsLocalChart(args.Chart) {
+		return nil, errors.Errorf("local chart %q has no Flux equivalent", args.Chart)
+	}
+	repo := ""
+	if args.RepositoryOpts.Repo != nil {
+		repo = *args.RepositoryOpts.Repo
+	}
+	if repo == "" {
+		return nil, errors.Errorf("chart %q has no repository to use as the Flux source", args.Chart)
+	}
+
+	name := args.Chart
+	if args.Name != nil && *args.Name != "" {
+		name = *args.Name
+	}
+	metadata := map[string]interface{}{"name": name}
+	if args.Namespace != nil && *args.Namespace != "" {
+		metadata["namespace"] = *args.Namespace
+	}
+
+	chart := map[string]interface{}{
+		"chart":     args.Chart,
+		"sourceRef": map[string]interface{}{"kind": "HelmRepository", "name": FluxRepositoryName(repo)},
+	}
+	if args.Version != nil && *args.Version != "" {
+		chart["version"] = *args.Version
+	}
+
+	spec := map[string]interface{}{
+		"interval": FluxDefaultInterval,
+		"chart":    map[string]interface{}{"spec": chart},
+	}
+	if args.Name != nil && *args.Name != "" {
+		spec["releaseName"] = *args.Name
+	}
+	if len(args.Values) > 0 {
+		spec["values"] = args.Values
+	}
+	if args.Timeout != nil {
+		spec["timeout"] = fmt.Sprintf("%ds", *args.Timeout)
+	}
+	if args.MaxHistory != nil {
+		spec["maxHistory"] = *args.MaxHistory
+	}
+
+	install, upgrade := make(map[string]interface{}), make(map[string]interface{})
+	setIf := func(m map[string]interface{}, key string, v *bool) {
+		if v != nil {
+			m[key] = *v
+		}
+	}
+	setIf(install, "createNamespace", args.CreateNamespace)
+	setIf(install, "replace", args.Replace)
+	for _, m := range []map[string]interface{}{install, upgrade} {
+		setIf(m, "disableWait", args.SkipAwait)
+		setIf(m, "disableHooks", args.DisableWebhooks)
+		setIf(m, "disableOpenAPIValidation", args.DisableOpenapiValidation)
+	}
+	if isTrue(args.SkipCrds) {
+		install["crds"] = "Skip"
+	}
+	setIf(upgrade, "force", args.ForceUpdate)
+	setIf(upgrade, "cleanupOnFail", args.CleanupOnFail)
+	if len(install) > 0 {
+		spec["install"] = install
+	}
+	if len(upgrade) > 0 {
+		spec["upgrade"] = upgrade
+	}
+
+	return yaml.Marshal(yaml.MapSlice{
+		{Key: "apiVersion", Value: FluxHelmReleaseAPIVersion},
+		{Key: "kind", Value: "HelmRelease"},
+		{Key: "metadata", Value: metadata},
+		{Key: "spec", Value: spec},
+	})
+}
+
+var nonDNSLabelChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// FluxRepositoryName derives the name of the Flux HelmRepository that ToFluxHelmRelease
+// refers to from the repository URL, as a valid Kubernetes name built from its host and
+// path: `https://charts.bitnami.com/bitnami` becomes `charts-bitnami-com-bitnami`.
+func FluxRepositoryName(repoURL string) string {
+	s := repoURL
+	if u, err := url.Parse(repoURL); err == nil && u.Host != "" {
+		s = u.Host + u.Path
+	}
+	s = strings.Trim(nonDNSLabelChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(s) > 63 {
+		s = strings.TrimRight(s[:63], "-")
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+)
+
+func TestToFluxHelmReleaseCoreFields(t *testing.T) {
+	args := &ReleaseType{
+		Chart:           "nginx",
+		Version:         strPtr("1.2.5"),
+		Name:            strPtr("web"),
+		Namespace:       strPtr("apps"),
+		RepositoryOpts:  helmv3.RepositoryOpts{Repo: strPtr("https://charts.example.com/stable")},
+		Values:          map[string]interface{}{"replicaCount": 2},
+		Timeout:         intPtr(300),
+		MaxHistory:      intPtr(5),
+		CreateNamespace: boolPtr(true),
+		SkipAwait:       boolPtr(true),
+		SkipCrds:        boolPtr(true),
+		ForceUpdate:     boolPtr(false),
+		CleanupOnFail:   boolPtr(true),
+	}
+	got, err := ToFluxHelmRelease(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `apiVersion: helm.toolkit.fluxcd.io/v2beta1
+kind: HelmRelease
+metadata:
+  name: web
+  namespace: apps
+spec:
+  chart:
+    spec:
+      chart: nginx
+      sourceRef:
+        kind: HelmRepository
+        name: charts-example-com-stable
+      version: 1.2.5
+  install:
+    crds: Skip
+    createNamespace: true
+    disableWait: true
+  interval: 5m
+  maxHistory: 5
+  releaseName: web
+  timeout: 300s
+  upgrade:
+    cleanupOnFail: true
+    disableWait: true
+    force: false
+  values:
+    replicaCount: 2
+`
+	if string(got) != want {
+		t.Errorf("manifest =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestToFluxHelmReleaseRejectsUnsourcedCharts(t *testing.T) {
+	local := writeChart(t, filepath.Join(t.TempDir(), "nginx"), "apiVersion: v2\nname: nginx\nversion: 1.0.0\n")
+	for _, tc := range []struct {
+		name string
+		args *ReleaseType
+		err  string
+	}{
+		{"no chart", &ReleaseType{}, "release has no chart"},
+		{"local chart", &ReleaseType{Chart: local}, "has no Flux equivalent"},
+		{"no repo", &ReleaseType{Chart: "nginx"}, "has no repository"},
+	} {
+		if _, err := ToFluxHelmRelease(tc.args); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: err = %v, want %q", tc.name, err, tc.err)
+		}
+	}
+}
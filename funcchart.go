@@ -0,0 +1,54 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// funcChart is a Chart whose behavior is supplied by plain values and a closure.
+type funcChart struct {
+	pulumi.ResourceState
+
+	typ        string
+	chart      string
+	repo       string
+	setOutputs func(out helmv3.ReleaseStatusOutput)
+	release    *helmv3.Release
+}
+
+// FuncChart returns a ready-to-use Chart for the given type token, default chart name,
+// and default repo URL, without needing to declare a new type. The setOutputs closure,
+// which may be nil, receives the Release status once it has been created. This is handy
+// for prototyping components and for tests. The returned chart also has a
+// `Release() *helmv3.Release` method, returning the Release once it has been created.
+func FuncChart(typ, chart, repo string, setOutputs func(out helmv3.ReleaseStatusOutput)) Chart {
+	return &funcChart{typ: typ, chart: chart, repo: repo, setOutputs: setOutputs}
+}
+
+func (c *funcChart) Type() string             { return c.typ }
+func (c *funcChart) DefaultChartName() string { return c.chart }
+func (c *funcChart) DefaultRepoURL() string   { return c.repo }
+func (c *funcChart) DefaultNamespace() string { return "" }
+
+func (c *funcChart) SetOutputs(out helmv3.ReleaseStatusOutput) {
+	if c.setOutputs != nil {
+		c.setOutputs(out)
+	}
+}
+
+func (c *funcChart) SetRelease(rel *helmv3.Release) { c.release = rel }
+func (c *funcChart) Release() *helmv3.Release       { return c.release }
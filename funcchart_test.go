@@ -0,0 +1,59 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"strings"
+	"testing"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func TestFuncChartConstructs(t *testing.T) {
+	mocks := &testMocks{}
+	var status *helmv3.ReleaseStatusOutput
+	c := FuncChart(testType, "redis", "https://charts.example.com/redis", func(out helmv3.ReleaseStatusOutput) {
+		status = &out
+	})
+	res, err := constructMocked(t, mocks, c, &testArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := mocks.release(t).Inputs
+	if got := inputs["chart"]; !got.IsString() || got.StringValue() != "redis" {
+		t.Errorf("chart = %v, want redis", got)
+	}
+	repo := inputs["repositoryOpts"]
+	if !repo.IsObject() || repo.ObjectValue()["repo"].StringValue() != "https://charts.example.com/redis" {
+		t.Errorf("repositoryOpts = %v, want the default repo", repo)
+	}
+	if status == nil {
+		t.Error("setOutputs wasn't called")
+	}
+	rel := c.(interface{ Release() *helmv3.Release }).Release()
+	if rel == nil || rel != res.Release {
+		t.Errorf("Release() = %v, want the constructed release", rel)
+	}
+	if !strings.HasSuffix(string(resolve(t, rel.URN()).(pulumi.URN)), "::test-helm") {
+		t.Error("Release() isn't the test-helm release")
+	}
+
+	// A nil setOutputs is allowed.
+	if _, err := constructMocked(t, &testMocks{}, FuncChart(testType, "redis", "", nil), &testArgs{}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ComputedPlaceholder stands in for any field whose value is an output that doesn't
+// resolve when marshaling ReleaseArgs.
+const ComputedPlaceholder = "<computed>"
+
+// OutputResolveTimeout bounds how long MarshalReleaseArgs waits for each output to resolve.
+// Outputs built from prompt values, which is all that To produces, resolve immediately.
+var OutputResolveTimeout = 5 * time.Second
+
+// MarshalReleaseArgs resolves the fields of the given ReleaseArgs and marshals them to
+// indented JSON, keyed by field name. Unset fields are omitted, outputs that don't resolve
+// are replaced with ComputedPlaceholder, and map keys are sorted, so the result is stable
+// and suitable for golden file tests of what To produces.
+func MarshalReleaseArgs(args *helmv3.ReleaseArgs) ([]byte, error) {
+	return json.MarshalIndent(resolveInput(reflect.ValueOf(args)), "", "    ")
+}
+
+func resolveInput(v reflect.Value) interface{} {
+	if v.CanInterface() {
+		switch t := v.Interface().(type) {
+		case pulumi.Output:
+			res, ok := awaitOutput(t)
+			if !ok {
+				return ComputedPlaceholder
+			}
+			return resolveInput(reflect.ValueOf(res))
+		case pulumi.Asset:
+			return map[string]interface{}{"path": t.Path(), "text": t.Text(), "uri": t.URI()}
+		case pulumi.Archive:
+			return map[string]interface{}{"path": t.Path(), "uri": t.URI()}
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return resolveInput(v.Elem())
+	case reflect.Struct:
+		res := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			if e := resolveInput(v.Field(i)); e != nil {
+				res[v.Type().Field(i).Name] = e
+			}
+		}
+		return res
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		res := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			res[fmt.Sprint(iter.Key().Interface())] = resolveInput(iter.Value())
+		}
+		return res
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		res := make([]interface{}, v.Len())
+		for i := range res {
+			res[i] = resolveInput(v.Index(i))
+		}
+		return res
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	default:
+		return nil
+	}
+}
+
+// awaitOutput waits up to OutputResolveTimeout for the output to resolve to a known value.
+func awaitOutput(o pulumi.Output) (interface{}, bool) {
+	ch := make(chan interface{}, 1)
+	o.ApplyT(func(v interface{}) interface{} {
+		ch <- v
+		return v
+	})
+	select {
+	case v := <-ch:
+		return v, true
+	case <-time.After(OutputResolveTimeout):
+		return nil, false
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// fullReleaseType returns a ReleaseType with every field that To copies onto ReleaseArgs
+// set to a non-zero value, along with the names of those fields.
+func fullReleaseType(t *testing.T) (*ReleaseType, []string) {
+	t.Helper()
+	var rel ReleaseType
+	names := fillInputs(t, reflect.ValueOf(&rel).Elem(), reflect.TypeOf(helmv3.ReleaseArgs{}))
+	return &rel, names
+}
+
+func fillInputs(t *testing.T, v reflect.Value, args reflect.Type) []string {
+	t.Helper()
+	var names []string
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if _, ok := args.FieldByName(f.Name); !ok || f.PkgPath != "" || releaseOutputOnlyFields[f.Name] {
+			continue
+		}
+		fv := v.Field(i)
+		switch fv.Interface().(type) {
+		case *bool:
+			fv.Set(reflect.ValueOf(boolPtr(true)))
+		case *int:
+			fv.Set(reflect.ValueOf(intPtr(i + 1)))
+		case *string:
+			fv.Set(reflect.ValueOf(strPtr("v-" + f.Name)))
+		case string:
+			fv.SetString("v-" + f.Name)
+		case map[string]interface{}:
+			fv.Set(reflect.ValueOf(map[string]interface{}{"key": f.Name}))
+		case map[string][]string:
+			fv.Set(reflect.ValueOf(map[string][]string{"key": {f.Name}}))
+		case []pulumi.AssetOrArchive:
+			fv.Set(reflect.ValueOf([]pulumi.AssetOrArchive{pulumi.NewStringAsset("key: " + f.Name)}))
+		default:
+			nested, ok := nestedArgsTypes[fv.Type()]
+			if !ok {
+				t.Fatalf("don't know how to fill ReleaseType.%s of type %v", f.Name, fv.Type())
+			}
+			fillInputs(t, fv, nested)
+		}
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// mustTo converts rel with To, failing the test on error.
+func mustTo(t *testing.T, rel *ReleaseType) *helmv3.ReleaseArgs {
+	t.Helper()
+	args, err := To(rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return args
+}
+
+func TestMarshalReleaseArgsStableAndComplete(t *testing.T) {
+	rel, names := fullReleaseType(t)
+	first, err := MarshalReleaseArgs(mustTo(t, rel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		again, err := MarshalReleaseArgs(mustTo(t, rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(first, again) {
+			t.Fatalf("marshaling is unstable:\n%s\nvs\n%s", first, again)
+		}
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(first, &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if _, ok := got[name]; !ok {
+			t.Errorf("field %s is missing from the marshaled args", name)
+		}
+	}
+	if len(got) != len(names) {
+		t.Errorf("marshaled %d fields, want %d: %s", len(got), len(names), first)
+	}
+	if s := fmt.Sprint(got["Chart"]); s != "v-Chart" {
+		t.Errorf("Chart = %s, want v-Chart", s)
+	}
+}
+
+func TestMarshalReleaseArgsUnresolvedOutput(t *testing.T) {
+	defer func(d time.Duration) { OutputResolveTimeout = d }(OutputResolveTimeout)
+	OutputResolveTimeout = 10 * time.Millisecond
+
+	// An output that is never resolved stands in for one computed during a preview.
+	pending, _, _ := pulumi.NewOutput()
+	version := pending.ApplyT(func(v interface{}) string { return fmt.Sprint(v) }).(pulumi.StringOutput)
+	args := &helmv3.ReleaseArgs{Chart: pulumi.String("nginx"), Version: version}
+	data, err := MarshalReleaseArgs(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"Chart": "nginx", "Version": ComputedPlaceholder}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// manualTo is the field-by-field mapping that To replaced, less the Manifest output,
+// which To no longer sends.
+func manualTo(args *ReleaseType) *helmv3.ReleaseArgs {
+	return &helmv3.ReleaseArgs{
+		Atomic:                   toBoolPtr(args.Atomic),
+		Chart:                    pulumi.String(args.Chart),
+		CleanupOnFail:            toBoolPtr(args.CleanupOnFail),
+		CreateNamespace:          toBoolPtr(args.CreateNamespace),
+		DependencyUpdate:         toBoolPtr(args.DependencyUpdate),
+		Description:              toStringPtr(args.Description),
+		Devel:                    toBoolPtr(args.Devel),
+		DisableCRDHooks:          toBoolPtr(args.DisableCRDHooks),
+		DisableOpenapiValidation: toBoolPtr(args.DisableOpenapiValidation),
+		DisableWebhooks:          toBoolPtr(args.DisableWebhooks),
+		ForceUpdate:              toBoolPtr(args.ForceUpdate),
+		Keyring:                  toStringPtr(args.Keyring),
+		Lint:                     toBoolPtr(args.Lint),
+		MaxHistory:               toIntPtr(args.MaxHistory),
+		Name:                     toStringPtr(args.Name),
+		Namespace:                toStringPtr(args.Namespace),
+		Postrender:               toStringPtr(args.Postrender),
+		RecreatePods:             toBoolPtr(args.RecreatePods),
+		RenderSubchartNotes:      toBoolPtr(args.RenderSubchartNotes),
+		Replace:                  toBoolPtr(args.Replace),
+		RepositoryOpts: &helmv3.RepositoryOptsArgs{
+			CaFile:   toStringPtr(args.RepositoryOpts.CaFile),
+			CertFile: toStringPtr(args.RepositoryOpts.CertFile),
+			KeyFile:  toStringPtr(args.RepositoryOpts.KeyFile),
+			Password: toStringPtr(args.RepositoryOpts.Password),
+			Repo:     toStringPtr(args.RepositoryOpts.Repo),
+			Username: toStringPtr(args.RepositoryOpts.Username),
+		},
+		ResetValues:    toBoolPtr(args.ResetValues),
+		ResourceNames:  pulumi.ToStringArrayMap(args.ResourceNames),
+		ReuseValues:    toBoolPtr(args.ReuseValues),
+		SkipAwait:      toBoolPtr(args.SkipAwait),
+		SkipCrds:       toBoolPtr(args.SkipCrds),
+		Timeout:        toIntPtr(args.Timeout),
+		ValueYamlFiles: toAssetOrArchiveArray(args.ValueYamlFiles),
+		Values:         pulumi.ToMap(args.Values),
+		Verify:         toBoolPtr(args.Verify),
+		Version:        toStringPtr(args.Version),
+		WaitForJobs:    toBoolPtr(args.WaitForJobs),
+	}
+}
+
+func TestToDropsNothing(t *testing.T) {
+	rel, _ := fullReleaseType(t)
+	got, want := reflect.ValueOf(*mustTo(t, rel)), reflect.ValueOf(*manualTo(rel))
+	for i := 0; i < got.NumField(); i++ {
+		name := got.Type().Field(i).Name
+		// The inputs hold outputs that resolve concurrently, so their values are compared.
+		g, w := resolve(t, pulumi.ToOutput(got.Field(i).Interface())), resolve(t, pulumi.ToOutput(want.Field(i).Interface()))
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("%s = %#v, want %#v", name, g, w)
+		}
+	}
+}
+
+func TestCopyInputsUnconvertibleField(t *testing.T) {
+	src := struct{ Timeout float64 }{1.5}
+	var dst helmv3.ReleaseArgs
+	err := copyInputs(reflect.ValueOf(src), reflect.ValueOf(&dst).Elem())
+	if err == nil || err.Error() != "converting Timeout: no input conversion from float64 to pulumi.IntPtrInput" {
+		t.Errorf("err = %v, want an unconvertible Timeout", err)
+	}
+}
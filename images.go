@@ -0,0 +1,60 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"sort"
+)
+
+// podContainerFields are the fields of a pod spec that hold lists of containers.
+var podContainerFields = []string{"containers", "initContainers", "ephemeralContainers"}
+
+// ExtractImages walks the given rendered manifests and returns the sorted, de-duplicated
+// container images referenced by any pod spec within them. Pod specs are found wherever
+// they're nested, so Deployments, CronJobs, and bare Pods are all covered.
+func ExtractImages(manifests map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	collectImages(manifests, seen)
+
+	images := make([]string, 0, len(seen))
+	for img := range seen {
+		images = append(images, img)
+	}
+	sort.Strings(images)
+	return images
+}
+
+func collectImages(v interface{}, seen map[string]bool) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for _, f := range podContainerFields {
+			containers, _ := t[f].([]interface{})
+			for _, c := range containers {
+				if c, ok := c.(map[string]interface{}); ok {
+					if img, ok := c["image"].(string); ok && img != "" {
+						seen[img] = true
+					}
+				}
+			}
+		}
+		for _, e := range t {
+			collectImages(e, seen)
+		}
+	case []interface{}:
+		for _, e := range t {
+			collectImages(e, seen)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// sampleManifest is a rendered release manifest, keyed like the Release's `manifest` output,
+// holding a Deployment, a CronJob, a bare Pod, and an object with no pod spec at all.
+const sampleManifest = `{
+	"apps/v1/Deployment/default/web": {
+		"kind": "Deployment",
+		"spec": {"template": {"spec": {
+			"initContainers": [{"name": "migrate", "image": "example.com/migrate:1.0"}],
+			"containers": [
+				{"name": "web", "image": "nginx:1.21"},
+				{"name": "sidecar", "image": "envoyproxy/envoy:v1.22"}
+			]
+		}}}
+	},
+	"batch/v1/CronJob/default/backup": {
+		"kind": "CronJob",
+		"spec": {"jobTemplate": {"spec": {"template": {"spec": {
+			"containers": [{"name": "backup", "image": "example.com/backup:2.3"}]
+		}}}}}
+	},
+	"v1/Pod/default/debug": {
+		"kind": "Pod",
+		"spec": {
+			"containers": [{"name": "debug", "image": "nginx:1.21"}],
+			"ephemeralContainers": [{"name": "shell", "image": "busybox"}]
+		}
+	},
+	"v1/ConfigMap/default/config": {
+		"kind": "ConfigMap",
+		"data": {"image": "not-a-container-image"}
+	}
+}`
+
+func TestExtractImages(t *testing.T) {
+	var manifests map[string]interface{}
+	if err := json.Unmarshal([]byte(sampleManifest), &manifests); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"busybox",
+		"envoyproxy/envoy:v1.22",
+		"example.com/backup:2.3",
+		"example.com/migrate:1.0",
+		"nginx:1.21",
+	}
+	if got := ExtractImages(manifests); !reflect.DeepEqual(got, want) {
+		t.Errorf("images = %v, want %v", got, want)
+	}
+	if got := ExtractImages(nil); len(got) != 0 {
+		t.Errorf("images of an empty manifest = %v, want none", got)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// KeyringDir is the directory CombineKeyrings writes combined keyrings to. It is relative
+// to the program's working directory, the Pulumi project directory, so the resulting
+// `keyring` input is the same on every machine and the file survives between runs.
+var KeyringDir = filepath.Join(".helmbase", "keyrings")
+
+// CombineKeyrings concatenates the given public keyring files into a single keyring that
+// Helm can use for verification, and returns its path. OpenPGP keyrings are simply
+// sequences of key packets, so concatenation yields a keyring holding every key. The
+// combined file lives in KeyringDir and is named after a hash of its contents, so the path
+// is stable across runs and machines and doesn't cause spurious diffs.
+func CombineKeyrings(paths []string) (string, error) {
+	var combined []byte
+	for _, p := range paths {
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return "", errors.Wrap(err, "reading keyring")
+		}
+		combined = append(combined, data...)
+	}
+
+	sum := sha256.Sum256(combined)
+	path := filepath.Join(KeyringDir, "keyring-"+hex.EncodeToString(sum[:8])+".gpg")
+	if existing, err := ioutil.ReadFile(path); err == nil && bytes.Equal(existing, combined) {
+		return path, nil
+	}
+	if err := os.MkdirAll(KeyringDir, 0700); err != nil {
+		return "", errors.Wrap(err, "creating keyring directory")
+	}
+	if err := ioutil.WriteFile(path, combined, 0600); err != nil {
+		return "", errors.Wrap(err, "writing combined keyring")
+	}
+	return path, nil
+}
+
+// applyKeyrings combines the release's Keyring and Keyrings into the single Keyring that
+// Helm expects. This only matters when the release is being verified.
+func applyKeyrings(args *ReleaseType) error {
+	if !isTrue(args.Verify) || len(args.Keyrings) == 0 {
+		return nil
+	}
+	var paths []string
+	if args.Keyring != nil && *args.Keyring != "" {
+		paths = append(paths, *args.Keyring)
+	}
+	path, err := CombineKeyrings(append(paths, args.Keyrings...))
+	if err != nil {
+		return err
+	}
+	args.Keyring = &path
+	return nil
+}
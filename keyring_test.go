@@ -0,0 +1,70 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCombineKeyringsTwoKeyrings(t *testing.T) {
+	defer func(d string) { KeyringDir = d }(KeyringDir)
+	KeyringDir = filepath.Join(t.TempDir(), "keyrings")
+
+	a := writeFile(t, "a.gpg", "key-a")
+	b := writeFile(t, "b.gpg", "key-b")
+	rel := &ReleaseType{Verify: boolPtr(true), Keyring: &a, Keyrings: []string{b}}
+	if err := applyKeyrings(rel); err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(*rel.Keyring) != KeyringDir {
+		t.Errorf("keyring = %s, want one in %s", *rel.Keyring, KeyringDir)
+	}
+	data, err := ioutil.ReadFile(*rel.Keyring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "key-akey-b" {
+		t.Errorf("combined keyring = %q, want both keys", data)
+	}
+
+	// The same keyrings always combine to the same path, and different ones to another.
+	again, err := CombineKeyrings([]string{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != *rel.Keyring {
+		t.Errorf("path changed between runs: %s vs %s", again, *rel.Keyring)
+	}
+	swapped, err := CombineKeyrings([]string{b, a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if swapped == again {
+		t.Errorf("different keyrings share the path %s", swapped)
+	}
+}
+
+func TestApplyKeyringsOnlyWhenVerifying(t *testing.T) {
+	a := writeFile(t, "a.gpg", "key-a")
+	rel := &ReleaseType{Keyring: &a, Keyrings: []string{a}}
+	if err := applyKeyrings(rel); err != nil {
+		t.Fatal(err)
+	}
+	if *rel.Keyring != a {
+		t.Errorf("keyring = %s, want it untouched without verify", *rel.Keyring)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// KustomizeHelmOutputFile is the file, within the kustomization directory, that the
+// Kustomize post-renderer writes Helm's rendered manifests to. The kustomization must
+// list it under `resources` for the patches to apply to the chart's output.
+const KustomizeHelmOutputFile = "helm-output.yaml"
+
+// kustomizationFiles are the file names Kustomize recognizes as a kustomization.
+var kustomizationFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// KustomizePostRenderScript composes the shell script used as a Helm post-renderer to run
+// Kustomize. Helm pipes the rendered manifests to stdin, which the script saves as
+// KustomizeHelmOutputFile before running `kustomize build` over the directory.
+func KustomizePostRenderScript(kustomize, dir string) string {
+	return fmt.Sprintf("#!/bin/sh\nset -e\ncat > %s\nexec %s build %s\n",
+		shellQuote(filepath.Join(dir, KustomizeHelmOutputFile)), shellQuote(kustomize), shellQuote(dir))
+}
+
+// ConfigureKustomizePostRender sets up the release to post-render the chart's manifests
+// with Kustomize, using the kustomization in dir. The kustomize binary is looked up on the
+// PATH unless kustomize is a path. Because Helm's post-renderer must be a single
+// executable, a wrapper script is written to the temp directory and used as Postrender.
+func ConfigureKustomizePostRender(args *ReleaseType, kustomize, dir string) error {
+	if kustomize == "" {
+		kustomize = "kustomize"
+	}
+	bin, err := exec.LookPath(kustomize)
+	if err != nil {
+		return errors.Wrap(err, "locating kustomize")
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return errors.Wrap(err, "resolving kustomization directory")
+	}
+	if !hasKustomization(dir) {
+		return errors.Errorf("%s does not contain a kustomization file", dir)
+	}
+
+	// Name the script after its contents, so the path is stable and doesn't cause diffs.
+	script := KustomizePostRenderScript(bin, dir)
+	sum := sha256.Sum256([]byte(script))
+	path := filepath.Join(os.TempDir(), "helmbase-kustomize-"+hex.EncodeToString(sum[:8])+".sh")
+	if err := ioutil.WriteFile(path, []byte(script), 0700); err != nil {
+		return errors.Wrap(err, "writing post-render script")
+	}
+	args.Postrender = &path
+	return nil
+}
+
+func hasKustomization(dir string) bool {
+	for _, f := range kustomizationFiles {
+		if _, err := os.Stat(filepath.Join(dir, f)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// shellQuote quotes s for safe use as a single POSIX shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
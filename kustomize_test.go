@@ -0,0 +1,76 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKustomizePostRenderScript(t *testing.T) {
+	got := KustomizePostRenderScript("/usr/bin/kustomize", "/work/it's here")
+	want := "#!/bin/sh\nset -e\ncat > '/work/it'\\''s here/helm-output.yaml'\n" +
+		"exec '/usr/bin/kustomize' build '/work/it'\\''s here'\n"
+	if got != want {
+		t.Errorf("script =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestConfigureKustomizePostRender(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no POSIX shell available")
+	}
+
+	// A fake kustomize that reports how it was invoked and what Helm's output was.
+	bin := filepath.Join(t.TempDir(), "kustomize")
+	fake := "#!/bin/sh\necho \"$1 $2\"\ncat \"$2/" + KustomizeHelmOutputFile + "\"\n"
+	if err := ioutil.WriteFile(bin, []byte(fake), 0700); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	rel := &ReleaseType{}
+	if err := ConfigureKustomizePostRender(rel, bin, dir); err == nil ||
+		!strings.Contains(err.Error(), "does not contain a kustomization file") {
+		t.Fatalf("without a kustomization: err = %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources: []\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ConfigureKustomizePostRender(rel, bin, dir); err != nil {
+		t.Fatal(err)
+	}
+	if rel.Postrender == nil {
+		t.Fatal("postrender wasn't set")
+	}
+	defer os.Remove(*rel.Postrender)
+
+	cmd := exec.Command(*rel.Postrender)
+	cmd.Stdin = strings.NewReader("kind: Deployment\n")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "build " + dir + "\nkind: Deployment\n"; string(out) != want {
+		t.Errorf("post-render output = %q, want %q", out, want)
+	}
+
+	if err := ConfigureKustomizePostRender(&ReleaseType{}, filepath.Join(dir, "missing"), dir); err == nil {
+		t.Error("expected an error for a missing kustomize binary")
+	}
+}
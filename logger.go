@@ -0,0 +1,90 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Logger receives the diagnostics helmbase reports while constructing a chart. It lets
+// the validation and warning logic run, and be checked, outside of a Pulumi program.
+type Logger interface {
+	Warn(msg string) error
+	Info(msg string) error
+	Debug(msg string) error
+}
+
+// NewLogger creates the Logger used for each chart constructed. The default reports to the
+// Pulumi engine via ctx.Log; it may be replaced, for instance to capture messages in tests.
+// The resource is the one messages pertain to, and may be nil.
+var NewLogger = NewContextLogger
+
+// NewContextLogger returns a Logger that reports to the Pulumi engine through ctx.Log,
+// attributing each message to res, if it isn't nil.
+func NewContextLogger(ctx *pulumi.Context, res pulumi.Resource) Logger {
+	var args *pulumi.LogArgs
+	if res != nil {
+		args = &pulumi.LogArgs{Resource: res}
+	}
+	return &contextLogger{ctx: ctx, args: args}
+}
+
+type contextLogger struct {
+	ctx  *pulumi.Context
+	args *pulumi.LogArgs
+}
+
+func (l *contextLogger) Warn(msg string) error  { return l.ctx.Log.Warn(msg, l.args) }
+func (l *contextLogger) Info(msg string) error  { return l.ctx.Log.Info(msg, l.args) }
+func (l *contextLogger) Debug(msg string) error { return l.ctx.Log.Debug(msg, l.args) }
+
+// ReportWarnings logs the release's Warnings, along with any conflicts found by Validate,
+// as warnings.
+func ReportWarnings(l Logger, r *ReleaseType) error {
+	msgs := r.Warnings()
+	for _, conflict := range r.Validate() {
+		msgs = append(msgs, conflict.Error())
+	}
+	for _, msg := range msgs {
+		if err := l.Warn(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// strictLogger records the warnings it passes on, so that they can be turned into an
+// error, as with ReleaseType.WarningsAsErrors.
+type strictLogger struct {
+	Logger
+	warnings []string
+}
+
+func (l *strictLogger) Warn(msg string) error {
+	l.warnings = append(l.warnings, msg)
+	return l.Logger.Warn(msg)
+}
+
+// err returns an error listing the warnings recorded so far, if there were any.
+func (l *strictLogger) err() error {
+	if len(l.warnings) == 0 {
+		return nil
+	}
+	return errors.Errorf("%d warning(s) treated as errors because `warningsAsErrors` is set: %s",
+		len(l.warnings), strings.Join(l.warnings, "; "))
+}
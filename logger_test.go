@@ -0,0 +1,101 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/provider"
+)
+
+func TestNewLoggerReceivesConstructWarnings(t *testing.T) {
+	logs := &recordingLogger{}
+	var attributed []pulumi.Resource
+	old := NewLogger
+	NewLogger = func(_ *pulumi.Context, res pulumi.Resource) Logger {
+		attributed = append(attributed, res)
+		return logs
+	}
+	defer func() { NewLogger = old }()
+
+	c := &testChart{}
+	args := &testArgs{Helm: &ReleaseType{SkipCrds: boolPtr(true)}}
+	if _, err := constructMocked(t, &testMocks{}, c, args); err != nil {
+		t.Fatal(err)
+	}
+	if len(attributed) != 1 || attributed[0] != c {
+		t.Errorf("loggers created for %v, want one for the chart", attributed)
+	}
+	if !hasWarning(logs.warns, "`skipCrds` is true") {
+		t.Errorf("warnings = %v, want the skipCrds warning", logs.warns)
+	}
+}
+
+func TestReportWarnings(t *testing.T) {
+	logs := &recordingLogger{}
+	r := &ReleaseType{SkipCrds: boolPtr(true), ReuseValues: boolPtr(true), ResetValues: boolPtr(true)}
+	if err := ReportWarnings(logs, r); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"`skipCrds` is true, so CRD hooks have no CRDs to act on",
+		"`reuseValues` is ignored when `resetValues` is set",
+	} {
+		if !hasWarning(logs.warns, want) {
+			t.Errorf("warnings = %v, want %q", logs.warns, want)
+		}
+	}
+	if len(logs.infos) != 0 || len(logs.debugs) != 0 {
+		t.Errorf("infos = %v, debugs = %v, want only warnings", logs.infos, logs.debugs)
+	}
+}
+
+func TestWarningsAsErrors(t *testing.T) {
+	const skipCrds = "`skipCrds` is true, so CRD hooks have no CRDs to act on"
+	for _, strict := range []bool{false, true} {
+		var construct error
+		logs := recordLogs(t)
+		mocks := &testMocks{}
+		// A token of its own, so the only warning is the one asked for.
+		c := &tokenChart{token: "logger:index:Strict"}
+		args := &testArgs{Helm: &ReleaseType{SkipCrds: boolPtr(true), WarningsAsErrors: boolPtr(strict)}}
+		err := runMocked(t, mocks, false, func(ctx *pulumi.Context) error {
+			_, construct = ConstructExt(ctx, c, c.Type(), "test", args, provider.ConstructInputs{}, nil)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !hasWarning(logs.warns, skipCrds) {
+			t.Errorf("strict=%v: warnings = %v, want the skipCrds warning logged", strict, logs.warns)
+		}
+		releases := len(mocks.byType(testReleaseType))
+		if !strict {
+			if construct != nil || releases != 1 {
+				t.Errorf("strict=false: err = %v, releases = %d, want the release created", construct, releases)
+			}
+			continue
+		}
+		if construct == nil || !strings.Contains(construct.Error(), "1 warning(s) treated as errors") ||
+			!strings.Contains(construct.Error(), skipCrds) {
+			t.Errorf("strict=true: err = %v, want the warning turned into an error", construct)
+		}
+		if releases != 0 {
+			t.Errorf("strict=true: %d releases created, want none", releases)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/provider"
+)
+
+const (
+	testType        = "test:index:Chart"
+	testReleaseType = "kubernetes:helm.sh/v3:Release"
+)
+
+// testArgs are the strongly typed args of the charts used in tests.
+type testArgs struct {
+	Helm         *ReleaseType `pulumi:"helmOptions"`
+	ReplicaCount *int         `pulumi:"replicaCount"`
+}
+
+func (a *testArgs) R() **ReleaseType { return &a.Helm }
+
+// testMocks records every resource registered with it. By default, resources output
+// their inputs, and invokes return their arguments.
+type testMocks struct {
+	mu        sync.Mutex
+	resources []pulumi.MockResourceArgs
+
+	// newResource and call, if set, override the default behavior.
+	newResource func(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error)
+	call        func(args pulumi.MockCallArgs) (resource.PropertyMap, error)
+}
+
+func (m *testMocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	m.mu.Lock()
+	m.resources = append(m.resources, args)
+	m.mu.Unlock()
+	if m.newResource != nil {
+		return m.newResource(args)
+	}
+	return args.Name + "-id", args.Inputs, nil
+}
+
+func (m *testMocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	if m.call != nil {
+		return m.call(args)
+	}
+	return args.Args, nil
+}
+
+// byType returns the registered resources of the given type.
+func (m *testMocks) byType(typ string) []pulumi.MockResourceArgs {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var res []pulumi.MockResourceArgs
+	for _, r := range m.resources {
+		if r.TypeToken == typ {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// release returns the single Helm Release registered with the mocks.
+func (m *testMocks) release(t *testing.T) pulumi.MockResourceArgs {
+	t.Helper()
+	rels := m.byType(testReleaseType)
+	if len(rels) != 1 {
+		t.Fatalf("expected 1 release, got %d", len(rels))
+	}
+	return rels[0]
+}
+
+// releaseValues returns the Values input of the single Helm Release registered with the
+// mocks, whether or not it is secret.
+func (m *testMocks) releaseValues(t *testing.T) map[string]interface{} {
+	t.Helper()
+	values := m.release(t).Inputs["values"]
+	if values.IsSecret() {
+		values = values.SecretValue().Element
+	}
+	if !values.IsObject() {
+		return nil
+	}
+	return values.Mappable().(map[string]interface{})
+}
+
+// runMocked runs body as a Pulumi program against mocks, optionally as a preview.
+func runMocked(t *testing.T, mocks *testMocks, dryRun bool, body pulumi.RunFunc) error {
+	t.Helper()
+	return runMockedConfig(t, mocks, dryRun, nil, body)
+}
+
+// runMockedConfig behaves like runMocked, but runs the program with the given stack config,
+// keyed by "namespace:key".
+func runMockedConfig(t *testing.T, mocks *testMocks, dryRun bool, config map[string]string,
+	body pulumi.RunFunc) error {
+	t.Helper()
+	ctx, err := pulumi.NewContext(context.Background(), pulumi.RunInfo{
+		Project: "project",
+		Stack:   "stack",
+		Config:  config,
+		DryRun:  dryRun,
+		Mocks:   mocks,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+	return pulumi.RunWithContext(ctx, body)
+}
+
+// constructMocked constructs c with args against mocks, returning the result once the
+// program has finished.
+func constructMocked(t *testing.T, mocks *testMocks, c Chart, args ChartArgs) (*ConstructResultExt, error) {
+	t.Helper()
+	var res *ConstructResultExt
+	err := runMocked(t, mocks, false, func(ctx *pulumi.Context) error {
+		var err error
+		res, err = ConstructExt(ctx, c, c.Type(), "test", args, provider.ConstructInputs{}, nil)
+		return err
+	})
+	return res, err
+}
+
+// constructOutputs behaves like constructMocked, but also derives outputs from the result
+// with derive while the program runs, returning them once it has finished; see resolve.
+func constructOutputs(t *testing.T, mocks *testMocks, c Chart, args ChartArgs,
+	derive func(*ConstructResultExt) []pulumi.Output) ([]pulumi.Output, error) {
+	t.Helper()
+	var outs []pulumi.Output
+	err := runMocked(t, mocks, false, func(ctx *pulumi.Context) error {
+		res, err := ConstructExt(ctx, c, c.Type(), "test", args, provider.ConstructInputs{}, nil)
+		if err != nil {
+			return err
+		}
+		outs = derive(res)
+		return nil
+	})
+	return outs, err
+}
+
+// resolve waits for an output, possibly of a program that has since finished, to
+// resolve, and returns its value. An output derived from another, as by ApplyT, must be
+// derived while the program runs, which waits for it before finishing: the SDK reads an
+// output without locking when applying to it, racing with it being fulfilled.
+func resolve(t *testing.T, o pulumi.Output) interface{} {
+	t.Helper()
+	ch := make(chan interface{}, 1)
+	o.ApplyT(func(v interface{}) interface{} {
+		ch <- v
+		return v
+	})
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for output")
+		return nil
+	}
+}
+
+// chartBase supplies the Chart methods for test charts, which embed it alongside
+// pulumi.ResourceState. (The SDK only finds a ResourceState embedded directly.)
+type chartBase struct {
+	namespace string
+	status    helmv3.ReleaseStatusOutput
+}
+
+func (c *chartBase) Type() string                              { return testType }
+func (c *chartBase) DefaultChartName() string                  { return "nginx" }
+func (c *chartBase) DefaultRepoURL() string                    { return "https://charts.example.com" }
+func (c *chartBase) DefaultNamespace() string                  { return c.namespace }
+func (c *chartBase) SetOutputs(out helmv3.ReleaseStatusOutput) { c.status = out }
+
+// recordingLogger records the messages logged to it, by severity.
+type recordingLogger struct {
+	mu                   sync.Mutex
+	warns, infos, debugs []string
+}
+
+func (l *recordingLogger) Warn(msg string) error  { return l.record(&l.warns, msg) }
+func (l *recordingLogger) Info(msg string) error  { return l.record(&l.infos, msg) }
+func (l *recordingLogger) Debug(msg string) error { return l.record(&l.debugs, msg) }
+
+func (l *recordingLogger) record(msgs *[]string, msg string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*msgs = append(*msgs, msg)
+	return nil
+}
+
+// recordLogs makes every chart constructed during the test log to the returned logger.
+func recordLogs(t *testing.T) *recordingLogger {
+	l := &recordingLogger{}
+	old := NewLogger
+	NewLogger = func(*pulumi.Context, pulumi.Resource) Logger { return l }
+	t.Cleanup(func() { NewLogger = old })
+	return l
+}
+
+// testChart is a chart with no optional behavior.
+type testChart struct {
+	pulumi.ResourceState
+	chartBase
+}
+
+func strPtr(s string) *string { return &s }
+func boolPtr(b bool) *bool    { return &b }
+func intPtr(i int) *int       { return &i }
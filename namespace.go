@@ -0,0 +1,108 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	// LabelManagedBy is the well-known label recording which tool manages a resource.
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	// ManagedByPulumi is the LabelManagedBy value that the Kubernetes provider stamps onto
+	// the resources it creates.
+	ManagedByPulumi = "pulumi"
+)
+
+// IsManagedByPulumi reports whether the given labels mark a resource as created by Pulumi.
+func IsManagedByPulumi(labels map[string]string) bool {
+	return labels[LabelManagedBy] == ManagedByPulumi
+}
+
+// CheckNamespaceCollision warns when the release asks Helm to create its namespace, but
+// that namespace already exists and appears to be managed by another Pulumi resource,
+// since the two will fight over it. The namespace is looked up with LookupClusterObject in
+// the cluster the chart targets (see ClusterTargetFor); a namespace that doesn't exist yet,
+// as is usual on a first install, is no collision. If the lookup fails, for instance
+// because the cluster is created by the same program, the check is skipped with a warning.
+// Warnings are reported to log.
+func CheckNamespaceCollision(ctx *pulumi.Context, log Logger, c Chart, args *ReleaseType) error {
+	if !isTrue(args.CreateNamespace) || args.Namespace == nil || *args.Namespace == "" {
+		return nil
+	}
+	ns := *args.Namespace
+	obj, err := LookupClusterObject(ClusterTargetFor(ctx, c), "namespace", ns)
+	if err != nil {
+		return log.Warn(fmt.Sprintf("skipping the namespace collision check: %v", err))
+	}
+	if obj != nil && IsManagedByPulumi(obj.Labels) {
+		return log.Warn(fmt.Sprintf("namespace %q appears to be managed by another Pulumi resource; "+
+			"setting `createNamespace` may cause the two to conflict", ns))
+	}
+	return nil
+}
+
+// DefaultNamespaceFallback is the namespace EffectiveNamespace resolves to when nothing
+// else specifies one, matching Kubernetes' own default.
+const DefaultNamespaceFallback = "default"
+
+// DefaultsConfig describes the sources of defaults, besides the user's own settings, that
+// EffectiveNamespace considers.
+type DefaultsConfig struct {
+	// Namespace is the chart's default namespace, as returned by DefaultNamespace.
+	Namespace string
+	// NamespaceValuePaths are dotted paths of chart values that, when set, override the
+	// namespace the chart's templates render into, e.g. `namespaceOverride`.
+	NamespaceValuePaths []string
+	// Fallback is used when no other source sets a namespace. If empty,
+	// DefaultNamespaceFallback is used.
+	Fallback string
+}
+
+// DefaultsConfigFor returns the DefaultsConfig describing the given chart's defaults.
+func DefaultsConfigFor(c Chart) DefaultsConfig {
+	return DefaultsConfig{Namespace: c.DefaultNamespace()}
+}
+
+// EffectiveNamespace resolves the namespace the release's resources end up in, once all
+// defaulting is done. The sources are considered in this order, and the first that is set
+// wins:
+//
+//  1. the chart values at defaults.NamespaceValuePaths, in order, since the chart's
+//     templates use them in place of the release namespace;
+//  2. the release's Namespace, as set by the user;
+//  3. defaults.Namespace, the chart's default;
+//  4. defaults.Fallback, or DefaultNamespaceFallback.
+func EffectiveNamespace(args *ReleaseType, defaults DefaultsConfig) string {
+	for _, path := range defaults.NamespaceValuePaths {
+		if v, ok := getValuePath(args.Values, path); ok {
+			if ns, ok := v.(string); ok && ns != "" {
+				return ns
+			}
+		}
+	}
+	if args.Namespace != nil && *args.Namespace != "" {
+		return *args.Namespace
+	}
+	if defaults.Namespace != "" {
+		return defaults.Namespace
+	}
+	if defaults.Fallback != "" {
+		return defaults.Fallback
+	}
+	return DefaultNamespaceFallback
+}
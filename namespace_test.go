@@ -0,0 +1,241 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/provider"
+)
+
+// fakeCluster makes LookupClusterObject find the given objects, keyed by kind and name
+// (e.g. "namespace/apps"), for the rest of the test. It records the lookups made.
+func fakeCluster(t *testing.T, objects map[string]*ClusterObject) *[]string {
+	var lookups []string
+	old := LookupClusterObject
+	LookupClusterObject = func(target ClusterTarget, kind, name string) (*ClusterObject, error) {
+		lookups = append(lookups, kind+"/"+name)
+		return objects[kind+"/"+name], nil
+	}
+	t.Cleanup(func() { LookupClusterObject = old })
+	return &lookups
+}
+
+func TestCheckNamespaceCollision(t *testing.T) {
+	const collision = "appears to be managed by another Pulumi resource"
+	fakeCluster(t, map[string]*ClusterObject{
+		"namespace/managed":   {Labels: map[string]string{LabelManagedBy: ManagedByPulumi}},
+		"namespace/unmanaged": {Labels: map[string]string{"team": "apps"}},
+	})
+	for _, tc := range []struct {
+		ns   string
+		warn bool
+	}{
+		{"managed", true},
+		{"unmanaged", false},
+		// A namespace that doesn't exist yet is the usual case on a first install.
+		{"missing", false},
+	} {
+		logs := recordLogs(t)
+		args := &testArgs{Helm: &ReleaseType{Namespace: strPtr(tc.ns), CreateNamespace: boolPtr(true),
+			CheckNamespaceCollision: boolPtr(true)}}
+		if _, err := constructMocked(t, &testMocks{}, &testChart{}, args); err != nil {
+			t.Fatalf("%s: %v", tc.ns, err)
+		}
+		if got := hasWarning(logs.warns, collision); got != tc.warn {
+			t.Errorf("%s: warned = %v, want %v; warnings: %v", tc.ns, got, tc.warn, logs.warns)
+		}
+	}
+}
+
+func TestCheckNamespaceCollisionTwoInstances(t *testing.T) {
+	// Two instances of the same chart checking the same namespace mustn't collide either.
+	lookups := fakeCluster(t, nil)
+	err := runMocked(t, &testMocks{}, false, func(ctx *pulumi.Context) error {
+		for _, name := range []string{"first", "second"} {
+			args := &testArgs{Helm: &ReleaseType{Namespace: strPtr("apps"), CreateNamespace: boolPtr(true),
+				CheckNamespaceCollision: boolPtr(true)}}
+			c := &testChart{}
+			if _, err := ConstructExt(ctx, c, c.Type(), name, args, provider.ConstructInputs{}, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*lookups) != 2 {
+		t.Errorf("lookups = %v, want one per instance", *lookups)
+	}
+}
+
+func TestCheckNamespaceCollisionLookupFails(t *testing.T) {
+	old := LookupClusterObject
+	LookupClusterObject = func(ClusterTarget, string, string) (*ClusterObject, error) {
+		return nil, errors.New("cluster unreachable")
+	}
+	defer func() { LookupClusterObject = old }()
+
+	logs := recordLogs(t)
+	args := &testArgs{Helm: &ReleaseType{Namespace: strPtr("apps"), CreateNamespace: boolPtr(true),
+		CheckNamespaceCollision: boolPtr(true)}}
+	if _, err := constructMocked(t, &testMocks{}, &testChart{}, args); err != nil {
+		t.Fatal(err)
+	}
+	if !hasWarning(logs.warns, "skipping the namespace collision check: cluster unreachable") {
+		t.Errorf("warnings = %v, want the check skipped", logs.warns)
+	}
+}
+
+// targetedChart is a chart that installs into a cluster of its own.
+type targetedChart struct {
+	pulumi.ResourceState
+	chartBase
+}
+
+func (c *targetedChart) ClusterTarget() ClusterTarget {
+	return ClusterTarget{Kubeconfig: "/etc/kube/other", Context: "other"}
+}
+
+func TestCheckNamespaceCollisionUsesChartTarget(t *testing.T) {
+	var targets []ClusterTarget
+	old := LookupClusterObject
+	LookupClusterObject = func(target ClusterTarget, kind, name string) (*ClusterObject, error) {
+		targets = append(targets, target)
+		return nil, nil
+	}
+	defer func() { LookupClusterObject = old }()
+
+	args := &testArgs{Helm: &ReleaseType{Namespace: strPtr("apps"), CreateNamespace: boolPtr(true),
+		CheckNamespaceCollision: boolPtr(true)}}
+	if _, err := constructMocked(t, &testMocks{}, &targetedChart{}, args); err != nil {
+		t.Fatal(err)
+	}
+	want := ClusterTarget{Kubeconfig: "/etc/kube/other", Context: "other"}
+	if len(targets) != 1 || targets[0] != want {
+		t.Errorf("targets = %v, want [%v]", targets, want)
+	}
+}
+
+func TestDefaultNamespace(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		def       string
+		namespace *string
+		want      interface{}
+	}{
+		{"default applied", "ingress", nil, "ingress"},
+		{"user override", "ingress", strPtr("custom"), "custom"},
+		{"no default", "", nil, nil},
+	} {
+		mocks := &testMocks{}
+		c := &testChart{chartBase: chartBase{namespace: tc.def}}
+		if _, err := constructMocked(t, mocks, c, &testArgs{Helm: &ReleaseType{Namespace: tc.namespace}}); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		var got interface{}
+		if ns := mocks.release(t).Inputs["namespace"]; ns.IsString() {
+			got = ns.StringValue()
+		}
+		if got != tc.want {
+			t.Errorf("%s: namespace = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCheckNamespaceCollisionUsesCallerLogger(t *testing.T) {
+	fakeCluster(t, map[string]*ClusterObject{
+		"namespace/apps": {Labels: map[string]string{LabelManagedBy: ManagedByPulumi}},
+	})
+	old := NewLogger
+	NewLogger = func(*pulumi.Context, pulumi.Resource) Logger {
+		t.Error("NewLogger called; want the caller's logger used")
+		return &recordingLogger{}
+	}
+	defer func() { NewLogger = old }()
+
+	logs := &recordingLogger{}
+	args := &ReleaseType{Namespace: strPtr("apps"), CreateNamespace: boolPtr(true)}
+	err := runMocked(t, &testMocks{}, false, func(ctx *pulumi.Context) error {
+		return CheckNamespaceCollision(ctx, logs, &testChart{}, args)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasWarning(logs.warns, "appears to be managed by another Pulumi resource") {
+		t.Errorf("warnings = %v, want the collision reported to the caller's logger", logs.warns)
+	}
+}
+
+func TestCheckNamespaceCollisionNotStrict(t *testing.T) {
+	// Collisions depend on cluster state, so even strict mode only logs them.
+	fakeCluster(t, map[string]*ClusterObject{
+		"namespace/apps": {Labels: map[string]string{LabelManagedBy: ManagedByPulumi}},
+	})
+	logs := recordLogs(t)
+	args := &testArgs{Helm: &ReleaseType{Namespace: strPtr("apps"), CreateNamespace: boolPtr(true),
+		CheckNamespaceCollision: boolPtr(true), WarningsAsErrors: boolPtr(true)}}
+	// A token of its own, so strict mode has no other warnings to fail on.
+	c := &tokenChart{token: "namespace:index:Strict"}
+	if _, err := constructMocked(t, &testMocks{}, c, args); err != nil {
+		t.Fatal(err)
+	}
+	if !hasWarning(logs.warns, "appears to be managed by another Pulumi resource") {
+		t.Errorf("warnings = %v, want the collision logged", logs.warns)
+	}
+}
+
+func TestEffectiveNamespace(t *testing.T) {
+	paths := []string{"namespaceOverride", "global.namespace"}
+	for _, tc := range []struct {
+		name     string
+		args     *ReleaseType
+		defaults DefaultsConfig
+		want     string
+	}{
+		{"nothing set", &ReleaseType{}, DefaultsConfig{}, DefaultNamespaceFallback},
+		{"fallback", &ReleaseType{}, DefaultsConfig{Fallback: "sandbox"}, "sandbox"},
+		{"chart default over fallback", &ReleaseType{},
+			DefaultsConfig{Namespace: "monitoring", Fallback: "sandbox"}, "monitoring"},
+		{"user's namespace over chart default", &ReleaseType{Namespace: strPtr("apps")},
+			DefaultsConfig{Namespace: "monitoring"}, "apps"},
+		{"empty user namespace is unset", &ReleaseType{Namespace: strPtr("")},
+			DefaultsConfig{Namespace: "monitoring"}, "monitoring"},
+		{"value path over user's namespace",
+			&ReleaseType{Namespace: strPtr("apps"), Values: map[string]interface{}{"namespaceOverride": "web"}},
+			DefaultsConfig{NamespaceValuePaths: paths}, "web"},
+		{"nested value path",
+			&ReleaseType{Namespace: strPtr("apps"), Values: map[string]interface{}{
+				"global": map[string]interface{}{"namespace": "shared"}}},
+			DefaultsConfig{NamespaceValuePaths: paths}, "shared"},
+		{"first value path wins",
+			&ReleaseType{Values: map[string]interface{}{"namespaceOverride": "web",
+				"global": map[string]interface{}{"namespace": "shared"}}},
+			DefaultsConfig{NamespaceValuePaths: paths}, "web"},
+		{"empty and non-string values are skipped",
+			&ReleaseType{Namespace: strPtr("apps"), Values: map[string]interface{}{
+				"namespaceOverride": "", "global": map[string]interface{}{"namespace": 3}}},
+			DefaultsConfig{NamespaceValuePaths: paths}, "apps"},
+		{"value paths not consulted", &ReleaseType{Values: map[string]interface{}{"namespaceOverride": "web"}},
+			DefaultsConfig{}, DefaultNamespaceFallback},
+	} {
+		if got := EffectiveNamespace(tc.args, tc.defaults); got != tc.want {
+			t.Errorf("%s: EffectiveNamespace = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"fmt"
+	"strings"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ReleaseName returns the name of the release as installed in the cluster. This reflects
+// any defaulting of the name that happened after the user's inputs were supplied.
+func ReleaseName(rel *helmv3.Release) pulumi.StringOutput {
+	return statusString(rel.Status, func(st helmv3.ReleaseStatus) *string { return st.Name })
+}
+
+// statusString projects an optional string field out of a release status, resolving to ""
+// when Helm leaves it unset. Fields are projected with a single apply on the status, as
+// accessors like Name().Elem() chain a second apply onto the first, which races in the
+// Pulumi SDK while the first is being fulfilled.
+func statusString(status helmv3.ReleaseStatusOutput, field func(helmv3.ReleaseStatus) *string) pulumi.StringOutput {
+	return status.ApplyT(func(st helmv3.ReleaseStatus) string {
+		if v := field(st); v != nil {
+			return *v
+		}
+		return ""
+	}).(pulumi.StringOutput)
+}
+
+// statusRevision projects the revision out of a release status, resolving to 0 when Helm
+// leaves it unset; see statusString.
+func statusRevision(status helmv3.ReleaseStatusOutput) pulumi.IntOutput {
+	return status.ApplyT(func(st helmv3.ReleaseStatus) int {
+		if st.Revision != nil {
+			return *st.Revision
+		}
+		return 0
+	}).(pulumi.IntOutput)
+}
+
+// The operations reported by Operation.
+const (
+	OperationInstall = "install"
+	OperationUpgrade = "upgrade"
+)
+
+// Operation returns whether the release's last operation was an install or an upgrade.
+// Helm doesn't record this directly, so it is derived from the revision: the first
+// revision is an install, and every later one an upgrade.
+func Operation(rel *helmv3.Release) pulumi.StringOutput {
+	return rel.Status.ApplyT(func(st helmv3.ReleaseStatus) string {
+		if st.Revision != nil && *st.Revision > 1 {
+			return OperationUpgrade
+		}
+		return OperationInstall
+	}).(pulumi.StringOutput)
+}
+
+// Images returns the container images deployed by the release, as found in its rendered
+// manifest by ExtractImages.
+func Images(rel *helmv3.Release) pulumi.StringArrayOutput {
+	return rel.Manifest.ApplyT(func(m map[string]interface{}) []string {
+		return ExtractImages(m)
+	}).(pulumi.StringArrayOutput)
+}
+
+// ToStatusMap formats a release status as a custom resource status subresource, for
+// operator-style programs that mirror the component onto a CRD. The result has the
+// release's details at the top level, keyed in camelCase, along with a `phase` holding
+// the Helm status and a single `Ready` condition that is "True" once the release is
+// deployed.
+func ToStatusMap(out helmv3.ReleaseStatusOutput) pulumi.MapOutput {
+	return out.ApplyT(func(st helmv3.ReleaseStatus) map[string]interface{} {
+		ready := "False"
+		if st.Status == "deployed" {
+			ready = "True"
+		}
+		res := map[string]interface{}{
+			"phase": st.Status,
+			"conditions": []interface{}{
+				map[string]interface{}{
+					"type":    "Ready",
+					"status":  ready,
+					"reason":  statusReason(st.Status),
+					"message": fmt.Sprintf("Helm release status is %q", st.Status),
+				},
+			},
+		}
+		for key, v := range map[string]*string{
+			"releaseName": st.Name,
+			"namespace":   st.Namespace,
+			"chart":       st.Chart,
+			"version":     st.Version,
+			"appVersion":  st.AppVersion,
+		} {
+			if v != nil {
+				res[key] = *v
+			}
+		}
+		if st.Revision != nil {
+			res["revision"] = *st.Revision
+		}
+		return res
+	}).(pulumi.MapOutput)
+}
+
+// statusReason turns a Helm status, such as "pending-install", into a CamelCase condition
+// reason, such as "ReleasePendingInstall".
+func statusReason(status string) string {
+	reason := "Release"
+	for _, w := range strings.Split(status, "-") {
+		if w != "" {
+			reason += strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	return reason
+}
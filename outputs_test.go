@@ -0,0 +1,183 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// releaseStatusMocks returns mocks whose Helm Releases report status, as the provider
+// would once they are installed. The status is merged over the release's own name and
+// namespace; a release without a name is given a generated one.
+func releaseStatusMocks(status map[string]interface{}) *testMocks {
+	return &testMocks{newResource: func(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+		outs := args.Inputs.Copy()
+		if args.TypeToken == testReleaseType {
+			st := map[string]interface{}{"name": args.Name + "-1a2b3c4d", "status": "deployed"}
+			if name := args.Inputs["name"]; name.IsString() && name.StringValue() != "" {
+				st["name"] = name.StringValue()
+			}
+			for k, v := range status {
+				st[k] = v
+			}
+			outs["status"] = resource.NewObjectProperty(resource.NewPropertyMapFromMap(st))
+		}
+		return args.Name + "-id", outs, nil
+	}}
+}
+
+func TestReleaseNameDefaultedAndUserSet(t *testing.T) {
+	releaseName := func(res *ConstructResultExt) []pulumi.Output {
+		return []pulumi.Output{ReleaseName(res.Release)}
+	}
+	outs, err := constructOutputs(t, releaseStatusMocks(nil), &testChart{}, &testArgs{}, releaseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resolve(t, outs[0]); got != "test-helm-1a2b3c4d" {
+		t.Errorf("defaulted release name = %v, want the generated name", got)
+	}
+
+	args := &testArgs{Helm: &ReleaseType{Name: strPtr("my-nginx")}}
+	outs, err = constructOutputs(t, releaseStatusMocks(nil), &testChart{}, args, releaseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resolve(t, outs[0]); got != "my-nginx" {
+		t.Errorf("user-set release name = %v, want my-nginx", got)
+	}
+}
+
+func TestToStatusMap(t *testing.T) {
+	mocks := releaseStatusMocks(map[string]interface{}{
+		"namespace":  "apps",
+		"chart":      "nginx",
+		"version":    "1.2.5",
+		"appVersion": "1.21.0",
+		"revision":   3,
+	})
+	statusMap := func(res *ConstructResultExt) []pulumi.Output {
+		return []pulumi.Output{ToStatusMap(res.Status())}
+	}
+	outs, err := constructOutputs(t, mocks, &testChart{}, &testArgs{}, statusMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"phase": "deployed",
+		"conditions": []interface{}{
+			map[string]interface{}{
+				"type":    "Ready",
+				"status":  "True",
+				"reason":  "ReleaseDeployed",
+				"message": `Helm release status is "deployed"`,
+			},
+		},
+		"releaseName": "test-helm-1a2b3c4d",
+		"namespace":   "apps",
+		"chart":       "nginx",
+		"version":     "1.2.5",
+		"appVersion":  "1.21.0",
+		"revision":    3,
+	}
+	if got := resolve(t, outs[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("status map = %#v, want %#v", got, want)
+	}
+
+	// A release that isn't deployed yet isn't ready, and unset details are left out.
+	outs, err = constructOutputs(t, releaseStatusMocks(map[string]interface{}{"status": "pending-install"}),
+		&testChart{}, &testArgs{}, statusMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := resolve(t, outs[0]).(map[string]interface{})
+	cond := got["conditions"].([]interface{})[0].(map[string]interface{})
+	if got["phase"] != "pending-install" || cond["status"] != "False" || cond["reason"] != "ReleasePendingInstall" {
+		t.Errorf("pending status map = %#v", got)
+	}
+	if _, ok := got["revision"]; ok {
+		t.Errorf("pending status map has a revision: %#v", got)
+	}
+}
+
+func TestOperation(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status map[string]interface{}
+		want   string
+	}{
+		{"first revision", map[string]interface{}{"revision": 1}, OperationInstall},
+		{"later revision", map[string]interface{}{"revision": 4}, OperationUpgrade},
+		{"no revision", nil, OperationInstall},
+	} {
+		outs, err := constructOutputs(t, releaseStatusMocks(tc.status), &testChart{}, &testArgs{},
+			func(res *ConstructResultExt) []pulumi.Output {
+				return []pulumi.Output{Operation(res.Release)}
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := resolve(t, outs[0]); got != tc.want {
+			t.Errorf("%s: operation = %v, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+// enrichingChart derives an output named key from its release's status.
+type enrichingChart struct {
+	pulumi.ResourceState
+	chartBase
+	key string
+}
+
+func (c *enrichingChart) EnrichOutputs(out helmv3.ReleaseStatusOutput) pulumi.Map {
+	return pulumi.Map{c.key: out.ApplyT(func(st helmv3.ReleaseStatus) string {
+		return "http://" + *st.Name + ".svc"
+	}).(pulumi.StringOutput)}
+}
+
+func TestEnrichedOutputs(t *testing.T) {
+	c := &enrichingChart{key: "endpoint"}
+	var outputs pulumi.Map
+	var outputsErr error
+	_, err := constructOutputs(t, releaseStatusMocks(map[string]interface{}{"name": "web"}), c, &testArgs{},
+		func(res *ConstructResultExt) []pulumi.Output {
+			outputs, outputsErr = componentOutputs(c, res.Release)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outputsErr != nil {
+		t.Fatal(outputsErr)
+	}
+	if got := resolve(t, outputs["endpoint"].(pulumi.StringOutput)); got != "http://web.svc" {
+		t.Errorf("endpoint = %v, want it derived from the release status", got)
+	}
+	if _, ok := outputs[FieldHelmStatusOutput]; !ok {
+		t.Errorf("outputs = %v, want the built-in ones kept", outputs)
+	}
+
+	_, err = constructMocked(t, releaseStatusMocks(nil), &enrichingChart{key: FieldHelmStatusOutput}, &testArgs{})
+	if err == nil || !strings.Contains(err.Error(), "conflicts with a built-in output") {
+		t.Errorf("err = %v, want a conflict with the built-in status output", err)
+	}
+}
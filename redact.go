@@ -0,0 +1,194 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+// RedactedValue replaces any secret value before it is logged.
+const RedactedValue = "[redacted]"
+
+// DefaultSecretKeyPatterns are the key-name patterns always treated as secrets, for every
+// chart. See RedactValues for the pattern syntax.
+var DefaultSecretKeyPatterns = []string{"password", "token", "secret"}
+
+// SecretKeyPatternsProvider may optionally be implemented by a Chart to name further value
+// keys that are secret and redacted before logging, in addition to DefaultSecretKeyPatterns.
+type SecretKeyPatternsProvider interface {
+	SecretKeyPatterns() []string
+}
+
+// RedactionPatterns returns the secret key patterns in effect for the given chart: the
+// DefaultSecretKeyPatterns, followed by any the chart adds.
+func RedactionPatterns(c Chart) []string {
+	if p, ok := c.(SecretKeyPatternsProvider); ok {
+		return append(append([]string(nil), DefaultSecretKeyPatterns...), p.SecretKeyPatterns()...)
+	}
+	return DefaultSecretKeyPatterns
+}
+
+// RedactValues returns a deep copy of values in which every entry whose key matches one of
+// the patterns is replaced with RedactedValue, preserving the surrounding structure. The
+// input map is left untouched. Matching ignores case, and a pattern may be:
+//
+//   - a plain name, such as "password", matching any key that contains it;
+//   - a glob, such as "*_key", matching any key as a whole, where `*` matches any run of
+//     characters and `?` any one character;
+//   - a dotted path, such as "postgresql.auth.password" or "*.auth.*", matching the key at
+//     exactly that path from the root, where each segment may be a glob. Lists don't add a
+//     segment, so their elements share the list's path.
+func RedactValues(values map[string]interface{}, patterns []string) map[string]interface{} {
+	return redactMap(values, nil, patterns)
+}
+
+func redactMap(values map[string]interface{}, keys []string, patterns []string) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+	res := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		p := append(append([]string(nil), keys...), k)
+		if isSecretKey(p, patterns) {
+			res[k] = RedactedValue
+		} else {
+			res[k] = redactValue(v, p, patterns)
+		}
+	}
+	return res
+}
+
+func redactValue(v interface{}, keys []string, patterns []string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return redactMap(t, keys, patterns)
+	case []interface{}:
+		res := make([]interface{}, len(t))
+		for i, e := range t {
+			res[i] = redactValue(e, keys, patterns)
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+// redactError returns err with every secret held in values, as RedactValues would find it
+// under the patterns, replaced by RedactedValue in its message. Values may be a map or a
+// struct with `pulumi:"x"` tags. If the message holds no secret, err is returned as is.
+func redactError(err error, values interface{}, patterns []string) error {
+	if err == nil {
+		return nil
+	}
+	m, ok := values.(map[string]interface{})
+	if !ok {
+		// This only runs once decoding has failed, so decode what we can.
+		cfg := &mapstructure.DecoderConfig{TagName: "pulumi", Result: &m}
+		if d, derr := mapstructure.NewDecoder(cfg); derr == nil {
+			_ = d.Decode(values)
+		}
+	}
+	msg, redacted := err.Error(), false
+	for _, secret := range secretStrings(m, nil, patterns, false) {
+		if strings.Contains(msg, secret) {
+			msg, redacted = strings.ReplaceAll(msg, secret, RedactedValue), true
+		}
+	}
+	if !redacted {
+		return err
+	}
+	return errors.New(msg)
+}
+
+// secretStrings returns the string forms of the scalars in values that RedactValues would
+// redact, longest first, so that a secret containing another is replaced whole. Within a
+// secret key, every nested scalar is secret.
+func secretStrings(v interface{}, keys []string, patterns []string, secret bool) []string {
+	var res []string
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Invalid:
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			p := append(append([]string(nil), keys...), fmt.Sprint(iter.Key().Interface()))
+			res = append(res, secretStrings(iter.Value().Interface(), p, patterns,
+				secret || isSecretKey(p, patterns))...)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			res = append(res, secretStrings(rv.Index(i).Interface(), keys, patterns, secret)...)
+		}
+	default:
+		if s := fmt.Sprint(rv.Interface()); secret && s != "" {
+			res = append(res, s)
+		}
+	}
+	if keys == nil {
+		sort.Slice(res, func(i, j int) bool { return len(res[i]) > len(res[j]) })
+	}
+	return res
+}
+
+// isSecretKey reports whether the last of the given keys, at the path formed by them all,
+// matches any of the patterns.
+func isSecretKey(keys []string, patterns []string) bool {
+	key := strings.ToLower(keys[len(keys)-1])
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		switch {
+		case p == "":
+			continue
+		case strings.Contains(p, "."):
+			if matchPath(strings.Split(p, "."), keys) {
+				return true
+			}
+		case strings.ContainsAny(p, "*?["):
+			if ok, _ := path.Match(p, key); ok {
+				return true
+			}
+		case strings.Contains(key, p):
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath reports whether each of the glob segments matches the corresponding element
+// of keys, which must be the same length.
+func matchPath(segments, keys []string) bool {
+	if len(segments) != len(keys) {
+		return false
+	}
+	for i, seg := range segments {
+		if ok, _ := path.Match(seg, strings.ToLower(keys[i])); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,210 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// secretChart is a chart with its own secret key patterns.
+type secretChart struct {
+	pulumi.ResourceState
+	chartBase
+}
+
+func (c *secretChart) SecretKeyPatterns() []string { return []string{"apikey"} }
+
+func TestRedactionPatterns(t *testing.T) {
+	if got := RedactionPatterns(&testChart{}); !reflect.DeepEqual(got, DefaultSecretKeyPatterns) {
+		t.Errorf("default patterns = %v, want %v", got, DefaultSecretKeyPatterns)
+	}
+	// A chart's patterns extend the defaults, rather than replacing them.
+	want := append(append([]string(nil), DefaultSecretKeyPatterns...), "apikey")
+	if got := RedactionPatterns(&secretChart{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("chart patterns = %v, want %v", got, want)
+	}
+	if len(DefaultSecretKeyPatterns) != 3 {
+		t.Errorf("RedactionPatterns modified the defaults: %v", DefaultSecretKeyPatterns)
+	}
+}
+
+func TestResolvedArgsDebugLogsKeysOnly(t *testing.T) {
+	args := &ReleaseType{Chart: "nginx", Values: map[string]interface{}{
+		"apiKey":       "hunter2",
+		"replicaCount": 2,
+		"db":           map[string]interface{}{"host": "db.internal"},
+	}}
+	msg := ResolvedArgsDebug(args)
+	for _, leaf := range []string{"hunter2", "db.internal"} {
+		if strings.Contains(msg, leaf) {
+			t.Errorf("debug message leaks the value %q: %s", leaf, msg)
+		}
+	}
+	if !strings.HasSuffix(msg, "valueKeys=[apiKey db replicaCount]") {
+		t.Errorf("debug message = %s, want the sorted top-level keys", msg)
+	}
+}
+
+func TestConstructRedactsDefaultAndChartPatterns(t *testing.T) {
+	logs := recordLogs(t)
+	args := &testArgs{Helm: &ReleaseType{Values: map[string]interface{}{
+		"apiKey":   "hunter2",
+		"password": "s3cret",
+	}}}
+	c := &secretChart{}
+	if _, err := constructMocked(t, &testMocks{}, c, args); err != nil {
+		t.Fatal(err)
+	}
+	all := strings.Join(append(append(logs.debugs, logs.infos...), logs.warns...), "\n")
+	if strings.Contains(all, "hunter2") || strings.Contains(all, "s3cret") {
+		t.Errorf("logs = %s, want no secret values", all)
+	}
+
+	// Adding a pattern doesn't stop the default ones from redacting the password.
+	red := args.Helm.Redacted(RedactionPatterns(c))
+	if red.Values["apiKey"] != RedactedValue || red.Values["password"] != RedactedValue {
+		t.Errorf("redacted values = %v, want apiKey and password redacted", red.Values)
+	}
+}
+
+func TestRedactedUsesPatternsAndCopiesDefaults(t *testing.T) {
+	rel := &ReleaseType{
+		RepositoryOpts: helmv3.RepositoryOpts{Password: strPtr("hunter2")},
+		Values:         map[string]interface{}{"apiKey": "k", "db": map[string]interface{}{"password": "p"}},
+	}
+	applyValueDefaults(rel, map[string]interface{}{"auth": map[string]interface{}{"apiKey": "default-key"}, "port": 80})
+
+	red := rel.Redacted([]string{"apikey"})
+	if *red.RepositoryOpts.Password != RedactedValue {
+		t.Errorf("password = %q, want it masked", *red.RepositoryOpts.Password)
+	}
+	if red.Values["apiKey"] != RedactedValue {
+		t.Errorf("apiKey = %v, want it masked by the chart's pattern", red.Values["apiKey"])
+	}
+	if got := red.Values["db"].(map[string]interface{})["password"]; got != "p" {
+		t.Errorf("db.password = %v, want it kept, since the chart's patterns don't name it", got)
+	}
+	if red.defaultValues["auth.apiKey"] != RedactedValue || red.defaultValues["port"] != 80 {
+		t.Errorf("defaults = %v, want auth.apiKey masked and port kept", red.defaultValues)
+	}
+
+	// The original is untouched, and the two share no maps.
+	if *rel.RepositoryOpts.Password != "hunter2" || rel.Values["apiKey"] != "k" ||
+		rel.defaultValues["auth.apiKey"] != "default-key" {
+		t.Errorf("original was modified: %+v", rel)
+	}
+	red.defaultValues["port"] = 8080
+	if rel.defaultValues["port"] != 80 {
+		t.Error("the redacted copy shares its defaults with the original")
+	}
+	if got := ValueProvenance(rel)["auth.apiKey"]; got != ProvenanceDefault {
+		t.Errorf("original provenance = %q, want default", got)
+	}
+
+	if red := rel.Redacted(nil); red.Values["db"].(map[string]interface{})["password"] != RedactedValue {
+		t.Error("nil patterns should fall back to DefaultSecretKeyPatterns")
+	}
+}
+
+func TestRedactValuesPathsAndWildcards(t *testing.T) {
+	values := map[string]interface{}{
+		"postgresql": map[string]interface{}{
+			"auth":     map[string]interface{}{"password": "p1", "username": "admin"},
+			"password": "p2",
+		},
+		"redis": map[string]interface{}{
+			"auth": map[string]interface{}{"token": "t1", "enabled": true},
+		},
+		"tls_key":  "k1",
+		"tls_cert": "c1",
+		"hosts":    []interface{}{map[string]interface{}{"tls_key": "k2", "name": "web"}},
+	}
+	got := RedactValues(values, []string{"postgresql.auth.password", "*.auth.*", "*_key"})
+	want := map[string]interface{}{
+		"postgresql": map[string]interface{}{
+			// The path only matches at its exact depth, so postgresql.password is kept.
+			"auth":     map[string]interface{}{"password": RedactedValue, "username": RedactedValue},
+			"password": "p2",
+		},
+		"redis": map[string]interface{}{
+			"auth": map[string]interface{}{"token": RedactedValue, "enabled": RedactedValue},
+		},
+		"tls_key":  RedactedValue,
+		"tls_cert": "c1",
+		"hosts":    []interface{}{map[string]interface{}{"tls_key": RedactedValue, "name": "web"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RedactValues = %v, want %v", got, want)
+	}
+	if values["tls_key"] != "k1" {
+		t.Error("RedactValues modified its input")
+	}
+}
+
+func TestDecodeErrorsRedactSecrets(t *testing.T) {
+	old := ValuesDecodeHooks
+	ValuesDecodeHooks = []mapstructure.DecodeHookFunc{
+		func(from, to reflect.Type, v interface{}) (interface{}, error) {
+			if s, ok := v.(string); ok && s != "ok" {
+				return nil, fmt.Errorf("unexpected value %q", s)
+			}
+			return v, nil
+		},
+	}
+	defer func() { ValuesDecodeHooks = old }()
+
+	type dbArgs struct {
+		DBPassword *string `pulumi:"dbPassword"`
+	}
+	err := InitDefaults(&ReleaseType{}, "nginx", "", "", &dbArgs{DBPassword: strPtr("hunter2")})
+	if err == nil || strings.Contains(err.Error(), "hunter2") || !strings.Contains(err.Error(), RedactedValue) {
+		t.Errorf("InitDefaults err = %v, want the password redacted", err)
+	}
+
+	// Values that aren't secret are still reported, to help find the problem.
+	type hostArgs struct {
+		Host string `pulumi:"host"`
+	}
+	err = InitDefaults(&ReleaseType{}, "nginx", "", "", &hostArgs{Host: "example.com"})
+	if err == nil || !strings.Contains(err.Error(), `unexpected value "example.com"`) {
+		t.Errorf("InitDefaults err = %v, want the value reported", err)
+	}
+}
+
+func TestRedactError(t *testing.T) {
+	values := map[string]interface{}{
+		"auth": map[string]interface{}{"password": "hunter2", "user": "admin"},
+		"port": 80,
+	}
+	err := redactError(errors.New(`bad "hunter2" for admin on 80`), values, DefaultSecretKeyPatterns)
+	if got, want := err.Error(), `bad "[redacted]" for admin on 80`; got != want {
+		t.Errorf("redactError = %q, want %q", got, want)
+	}
+	plain := errors.New("no secrets here")
+	if got := redactError(plain, values, DefaultSecretKeyPatterns); got != plain {
+		t.Errorf("redactError = %v, want the original error", got)
+	}
+	if redactError(nil, values, DefaultSecretKeyPatterns) != nil {
+		t.Error("redactError(nil) isn't nil")
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// typeRegistry records, for each type token constructed in this program, the Go type of
+// the Chart that first used it.
+var typeRegistry = struct {
+	sync.Mutex
+	charts map[string]reflect.Type
+}{charts: make(map[string]reflect.Type)}
+
+// RegisterType records that the given chart uses its Type() token. Many instances of one
+// chart legitimately share a token, but two different charts sharing one confuses schema
+// generation and SDKs, so that case returns a warning naming both. It is safe to call
+// concurrently, and Construct calls it for every chart.
+func RegisterType(c Chart) string {
+	tok, t := c.Type(), reflect.TypeOf(c)
+
+	typeRegistry.Lock()
+	defer typeRegistry.Unlock()
+	prev, ok := typeRegistry.charts[tok]
+	if !ok {
+		typeRegistry.charts[tok] = t
+		return ""
+	}
+	if prev != t {
+		return fmt.Sprintf("type token %q is used by both %v and %v; each chart should have its own token",
+			tok, prev, t)
+	}
+	return ""
+}
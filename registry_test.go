@@ -0,0 +1,76 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// tokenChart is a chart with a configurable type token.
+type tokenChart struct {
+	pulumi.ResourceState
+	chartBase
+	token string
+}
+
+func (c *tokenChart) Type() string { return c.token }
+
+func TestRegisterTypeDuplicateTokenConcurrently(t *testing.T) {
+	const token = "registry:index:Duplicate"
+	const n = 50
+
+	var wg sync.WaitGroup
+	warnings := make(chan string, 2*n)
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			warnings <- RegisterType(&tokenChart{token: token})
+		}()
+		go func() {
+			defer wg.Done()
+			warnings <- RegisterType(FuncChart(token, "nginx", "", nil))
+		}()
+	}
+	wg.Wait()
+	close(warnings)
+
+	// Whichever chart registers first owns the token, and every use by the other warns.
+	var count int
+	for w := range warnings {
+		if w == "" {
+			continue
+		}
+		count++
+		if !strings.Contains(w, token) || !strings.Contains(w, "*helmbase.tokenChart") ||
+			!strings.Contains(w, "*helmbase.funcChart") {
+			t.Errorf("warning = %q, want it to name the token and both charts", w)
+		}
+	}
+	if count != n {
+		t.Errorf("got %d warnings, want %d", count, n)
+	}
+
+	if w := RegisterType(&tokenChart{token: "registry:index:Unique"}); w != "" {
+		t.Errorf("first use of a token warned: %s", w)
+	}
+	if w := RegisterType(&tokenChart{token: "registry:index:Unique"}); w != "" {
+		t.Errorf("another instance of the same chart warned: %s", w)
+	}
+}
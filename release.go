@@ -0,0 +1,181 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"reflect"
+	"strings"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+)
+
+// EqualReleaseInputs reports whether two releases have the same inputs. Fields that are
+// only populated once the release has been applied (Status, Manifest, and ResourceNames)
+// are ignored. This is primarily useful for tests.
+func EqualReleaseInputs(a, b *ReleaseType) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return reflect.DeepEqual(releaseInputs(a), releaseInputs(b))
+}
+
+// releaseInputs returns a shallow copy of the release with its output fields cleared.
+func releaseInputs(r *ReleaseType) ReleaseType {
+	res := *r
+	res.Manifest = nil
+	res.ResourceNames = nil
+	res.Status = helmv3.ReleaseStatus{}
+	res.defaultValues = nil
+	return res
+}
+
+// cloneRelease returns a deep copy of the release, which can be modified independently.
+func cloneRelease(r *ReleaseType) *ReleaseType {
+	res := *r
+	v := reflect.ValueOf(&res).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.CanSet() {
+			f.Set(deepCopy(f))
+		}
+	}
+	// Reflection can't set unexported fields, so copy those by hand.
+	res.defaultValues = deepCopy(reflect.ValueOf(r.defaultValues)).Interface().(map[string]interface{})
+	return &res
+}
+
+// deepCopy returns a copy of v that shares no pointers, maps, or slices with it. Values
+// held in interfaces, such as assets, are treated as immutable and shared.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.New(v.Type().Elem())
+		res.Elem().Set(deepCopy(v.Elem()))
+		return res
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			res.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return res
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			res.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return res
+	case reflect.Struct:
+		res := reflect.New(v.Type()).Elem()
+		res.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if f := res.Field(i); f.CanSet() {
+				f.Set(deepCopy(v.Field(i)))
+			}
+		}
+		return res
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		// Copy the nested values maps and slices hold, but share anything else.
+		switch v.Elem().Kind() {
+		case reflect.Map, reflect.Slice:
+			res := reflect.New(v.Type()).Elem()
+			res.Set(deepCopy(v.Elem()))
+			return res
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// Redacted returns a deep copy of the release that is safe to log: the repository
+// password is masked, as are any values, including the defaults behind them, or Manifest
+// entries whose keys match the given secret key patterns (see RedactValues). Pass
+// RedactionPatterns(c) for a chart's own patterns; nil means DefaultSecretKeyPatterns.
+func (r *ReleaseType) Redacted(patterns []string) *ReleaseType {
+	if patterns == nil {
+		patterns = DefaultSecretKeyPatterns
+	}
+	res := cloneRelease(r)
+	if res.RepositoryOpts.Password != nil {
+		masked := RedactedValue
+		res.RepositoryOpts.Password = &masked
+	}
+	res.Values = RedactValues(res.Values, patterns)
+	res.Manifest = RedactValues(res.Manifest, patterns)
+	// Defaults are keyed by dotted path, so match each against its full path.
+	for path, v := range res.defaultValues {
+		keys := strings.Split(path, ".")
+		if isSecretKey(keys, patterns) {
+			res.defaultValues[path] = RedactedValue
+		} else {
+			res.defaultValues[path] = redactValue(v, keys, patterns)
+		}
+	}
+	return res
+}
+
+// MergeReleaseTypes returns a new release that layers each of the overrides, in order, on
+// top of base, so that later overrides win. This suits configuration assembled from
+// several partial sources. Fields an override leaves unset, such as nil pointers, slices,
+// and maps or empty strings, keep the value beneath them; Values are deep merged, honoring
+// the merged ArrayMergeStrategies. Nil releases are skipped, and none of the arguments is
+// modified.
+func MergeReleaseTypes(base *ReleaseType, overrides ...*ReleaseType) *ReleaseType {
+	res := &ReleaseType{}
+	if base != nil {
+		res = cloneRelease(base)
+	}
+	for _, o := range overrides {
+		if o == nil {
+			continue
+		}
+		// Fields are copied wholesale, so restore the Values beneath the override's copy
+		// and merge the two.
+		values := res.Values
+		mergeSetFields(reflect.ValueOf(res).Elem(), reflect.ValueOf(o).Elem())
+		if o.Values != nil {
+			res.Values = MergeValuesWithStrategies(values, res.Values, arrayMergeStrategies(res))
+		}
+	}
+	return res
+}
+
+// mergeSetFields copies every non-zero exported field of src onto dst, recursing into
+// nested structs so that they, too, are merged field by field.
+func mergeSetFields(dst, src reflect.Value) {
+	for i := 0; i < src.NumField(); i++ {
+		d, s := dst.Field(i), src.Field(i)
+		if !d.CanSet() {
+			continue
+		}
+		if s.Kind() == reflect.Struct {
+			mergeSetFields(d, s)
+		} else if !s.IsZero() {
+			d.Set(deepCopy(s))
+		}
+	}
+}
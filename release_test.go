@@ -0,0 +1,86 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"reflect"
+	"testing"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+)
+
+func TestEqualReleaseInputsIgnoresOutputs(t *testing.T) {
+	a := &ReleaseType{Chart: "nginx", Version: strPtr("1.2.3"), Values: map[string]interface{}{"replicas": 2}}
+	b := &ReleaseType{Chart: "nginx", Version: strPtr("1.2.3"), Values: map[string]interface{}{"replicas": 2},
+		Status:        helmv3.ReleaseStatus{Status: "deployed", Revision: intPtr(3)},
+		Manifest:      map[string]interface{}{"kind": "Deployment"},
+		ResourceNames: map[string][]string{"Deployment.apps/v1": {"nginx"}},
+	}
+	if !EqualReleaseInputs(a, b) {
+		t.Error("releases differing only in outputs should be equal")
+	}
+	b.Version = strPtr("1.2.4")
+	if EqualReleaseInputs(a, b) {
+		t.Error("releases with different versions should differ")
+	}
+	if !EqualReleaseInputs(nil, nil) || EqualReleaseInputs(a, nil) {
+		t.Error("nil releases only equal each other")
+	}
+}
+
+func TestMergeReleaseTypesPrecedence(t *testing.T) {
+	base := &ReleaseType{Chart: "nginx", Version: strPtr("1.0.0"), Namespace: strPtr("web"), Timeout: intPtr(300),
+		RepositoryOpts: helmv3.RepositoryOpts{Repo: strPtr("https://charts.example.com")},
+		Values:         map[string]interface{}{"image": map[string]interface{}{"tag": "base", "pullPolicy": "Always"}}}
+	org := &ReleaseType{Version: strPtr("1.1.0"), Atomic: boolPtr(true),
+		RepositoryOpts: helmv3.RepositoryOpts{Username: strPtr("org")},
+		Values:         map[string]interface{}{"image": map[string]interface{}{"tag": "org"}, "replicas": 2}}
+	team := &ReleaseType{Version: strPtr("1.2.0"), Namespace: strPtr("team"),
+		Values: map[string]interface{}{"replicas": 3}}
+	stack := &ReleaseType{Version: strPtr("1.3.0"), Atomic: boolPtr(false),
+		Values: map[string]interface{}{"image": map[string]interface{}{"tag": "stack"}}}
+
+	got := MergeReleaseTypes(base, org, nil, team, stack)
+	for _, tc := range []struct {
+		field     string
+		got, want interface{}
+	}{
+		{"chart", got.Chart, "nginx"},
+		{"version", *got.Version, "1.3.0"},
+		{"namespace", *got.Namespace, "team"},
+		{"timeout", *got.Timeout, 300},
+		// An explicit false still overrides, since only nil pointers are unset.
+		{"atomic", *got.Atomic, false},
+		{"repo", *got.RepositoryOpts.Repo, "https://charts.example.com"},
+		{"username", *got.RepositoryOpts.Username, "org"},
+		{"values", got.Values, map[string]interface{}{
+			"image":    map[string]interface{}{"tag": "stack", "pullPolicy": "Always"},
+			"replicas": 3,
+		}},
+	} {
+		if !reflect.DeepEqual(tc.got, tc.want) {
+			t.Errorf("%s = %#v, want %#v", tc.field, tc.got, tc.want)
+		}
+	}
+
+	// None of the inputs is modified.
+	if *base.Version != "1.0.0" || base.Atomic != nil || base.RepositoryOpts.Username != nil ||
+		base.Values["image"].(map[string]interface{})["tag"] != "base" || base.Values["replicas"] != nil {
+		t.Errorf("base was modified: %+v", base)
+	}
+	if org.Values["image"].(map[string]interface{})["tag"] != "org" || *org.Version != "1.1.0" {
+		t.Errorf("an override was modified: %+v", org)
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"gopkg.in/yaml.v2"
+)
+
+// HelmBinary is the helm executable RenderManifest runs. It is looked up on the PATH
+// unless it is a path.
+var HelmBinary = "helm"
+
+// RenderManifest renders the release's chart locally with `helm template`, without
+// installing anything or contacting a cluster, and returns the resulting objects keyed by
+// "kind/namespace/name" (or "kind/name" for cluster-scoped objects). This suits CI checks
+// of what a release would deploy. The release should already have its defaults applied,
+// as by InitDefaults.
+//
+// Local and inline value files are passed to Helm; remote ones can't be, and are an error.
+// A repository password is never put on a command line, where other local users could
+// read it; see helmTemplateArgs.
+func RenderManifest(ctx context.Context, args *ReleaseType) (map[string]interface{}, error) {
+	bin, err := exec.LookPath(HelmBinary)
+	if err != nil {
+		return nil, errors.Wrap(err, "locating helm")
+	}
+
+	cmdArgs, prep, cleanup, err := helmTemplateArgs(args)
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range prep {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, bin, p.args...)
+		cmd.Stdin, cmd.Stderr = strings.NewReader(p.stdin), &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, errors.Wrapf(err, "preparing to render chart %q: helm %s: %s",
+				args.Chart, strings.Join(p.args[:2], " "), bytes.TrimSpace(stderr.Bytes()))
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, cmdArgs...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "rendering chart %q: %s", args.Chart, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return parseManifests(stdout.Bytes())
+}
+
+// helmCommand is a helm command that must run before `helm template`, with the given input.
+type helmCommand struct {
+	args  []string
+	stdin string
+}
+
+// renderRepoName is the name the release's repository is given in the temporary
+// repository config used to render charts from password-protected repositories.
+const renderRepoName = "helmbase"
+
+// helmTemplateArgs builds the `helm template` command line for the release, along with any
+// commands that must run first. Values are written to temporary files, which the returned
+// cleanup function removes.
+//
+// A repository password is handed to Helm without appearing in any command line: for a
+// classic repository, it is written to a private, temporary repository config, whose index
+// is fetched first; for an OCI registry, it is fed to `helm registry login` on stdin.
+func helmTemplateArgs(args *ReleaseType) ([]string, []helmCommand, func(), error) {
+	var files []string
+	cleanup := func() {
+		for _, f := range files {
+			os.RemoveAll(f)
+		}
+	}
+	writeValues := func(data []byte) (string, error) {
+		f, err := ioutil.TempFile("", "helmbase-values-*.yaml")
+		if err != nil {
+			return "", errors.Wrap(err, "writing values")
+		}
+		defer f.Close()
+		files = append(files, f.Name())
+		if _, err := f.Write(data); err != nil {
+			return "", errors.Wrap(err, "writing values")
+		}
+		return f.Name(), nil
+	}
+
+	name := "release"
+	if args.Name != nil && *args.Name != "" {
+		name = *args.Name
+	}
+	chart := args.Chart
+	opts := args.RepositoryOpts
+	var prep []helmCommand
+	var authFlags []string
+	if opts.Password != nil && *opts.Password != "" {
+		dir, err := ioutil.TempDir("", "helmbase-repo-")
+		if err != nil {
+			return nil, nil, cleanup, errors.Wrap(err, "writing repository config")
+		}
+		files = append(files, dir)
+		if IsOCIChart(chart) {
+			authFlags = []string{"--registry-config", filepath.Join(dir, "registry.json")}
+			login := []string{"registry", "login", ociRegistryHost(chart), "--password-stdin"}
+			if opts.Username != nil {
+				login = append(login, "--username", *opts.Username)
+			}
+			prep = append(prep, helmCommand{args: append(login, authFlags...), stdin: *opts.Password})
+			opts.Username, opts.Password = nil, nil
+		} else if opts.Repo != nil && *opts.Repo != "" {
+			config := filepath.Join(dir, "repositories.yaml")
+			if err := writeRepoConfig(config, opts); err != nil {
+				return nil, nil, cleanup, err
+			}
+			authFlags = []string{"--repository-config", config, "--repository-cache", filepath.Join(dir, "cache")}
+			prep = append(prep, helmCommand{args: append([]string{"repo", "update"}, authFlags...)})
+			chart = renderRepoName + "/" + chart
+			// The repository and its credentials now come from the config instead.
+			opts = helmv3.RepositoryOpts{}
+		} else {
+			return nil, nil, cleanup, errors.Errorf("chart %q can't be rendered with a repository password "+
+				"unless `repositoryOpts.repo` is set or it is an OCI chart", chart)
+		}
+	}
+
+	cmdArgs := append([]string{"template", name, chart}, authFlags...)
+	flag := func(name string, v *string) {
+		if v != nil && *v != "" {
+			cmdArgs = append(cmdArgs, "--"+name, *v)
+		}
+	}
+	flag("namespace", args.Namespace)
+	flag("version", args.Version)
+	flag("repo", opts.Repo)
+	flag("username", opts.Username)
+	flag("ca-file", opts.CaFile)
+	flag("cert-file", opts.CertFile)
+	flag("key-file", opts.KeyFile)
+	flag("keyring", args.Keyring)
+	flag("post-renderer", args.Postrender)
+	flag("description", args.Description)
+	boolFlag := func(name string, v *bool) {
+		if isTrue(v) {
+			cmdArgs = append(cmdArgs, "--"+name)
+		}
+	}
+	boolFlag("devel", args.Devel)
+	boolFlag("verify", args.Verify)
+	boolFlag("dependency-update", args.DependencyUpdate)
+	if isTrue(args.SkipCrds) {
+		cmdArgs = append(cmdArgs, "--skip-crds")
+	} else {
+		cmdArgs = append(cmdArgs, "--include-crds")
+	}
+
+	// Value files go first, so that the inline values win over them, as they do on install.
+	for i, f := range args.ValueYamlFiles {
+		a, ok := f.(pulumi.Asset)
+		if !ok || (a.Path() == "" && a.Text() == "") {
+			return nil, nil, cleanup, errors.Errorf("valueYamlFiles[%d] can't be read locally to render the chart", i)
+		}
+		path := a.Path()
+		if path == "" {
+			var err error
+			if path, err = writeValues([]byte(a.Text())); err != nil {
+				return nil, nil, cleanup, err
+			}
+		}
+		cmdArgs = append(cmdArgs, "--values", path)
+	}
+	if len(args.Values) > 0 {
+		data, err := yaml.Marshal(args.Values)
+		if err != nil {
+			return nil, nil, cleanup, errors.Wrap(err, "marshaling values")
+		}
+		path, err := writeValues(data)
+		if err != nil {
+			return nil, nil, cleanup, err
+		}
+		cmdArgs = append(cmdArgs, "--values", path)
+	}
+	return cmdArgs, prep, cleanup, nil
+}
+
+// writeRepoConfig writes a Helm repository config at path, readable only by its owner,
+// holding the single repository described by opts under renderRepoName.
+func writeRepoConfig(path string, opts helmv3.RepositoryOpts) error {
+	str := func(p *string) string {
+		if p == nil {
+			return ""
+		}
+		return *p
+	}
+	data, err := yaml.Marshal(map[string]interface{}{
+		"apiVersion": "",
+		"repositories": []map[string]string{{
+			"name":     renderRepoName,
+			"url":      str(opts.Repo),
+			"username": str(opts.Username),
+			"password": str(opts.Password),
+			"caFile":   str(opts.CaFile),
+			"certFile": str(opts.CertFile),
+			"keyFile":  str(opts.KeyFile),
+		}},
+	})
+	if err != nil {
+		return errors.Wrap(err, "marshaling repository config")
+	}
+	return errors.Wrap(ioutil.WriteFile(path, data, 0600), "writing repository config")
+}
+
+// ociRegistryHost returns the registry host, with any port, of an OCI chart reference.
+func ociRegistryHost(chart string) string {
+	host := strings.TrimPrefix(chart, "oci://")
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+	return host
+}
+
+// parseManifests splits a multi-document YAML stream of Kubernetes objects into a map
+// keyed by "kind/namespace/name". Empty documents are skipped.
+func parseManifests(data []byte) (map[string]interface{}, error) {
+	res := make(map[string]interface{})
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for i := 0; ; i++ {
+		var raw map[interface{}]interface{}
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "parsing rendered manifest %d", i)
+		}
+		if raw == nil {
+			continue
+		}
+		obj := normalizeYAML(raw).(map[string]interface{})
+		kind, _ := obj["kind"].(string)
+		meta, _ := obj["metadata"].(map[string]interface{})
+		name, _ := meta["name"].(string)
+		key := kind + "/" + name
+		if ns, _ := meta["namespace"].(string); ns != "" {
+			key = kind + "/" + ns + "/" + name
+		}
+		if _, dup := res[key]; dup {
+			key += "#" + strconv.Itoa(i)
+		}
+		res[key] = obj
+	}
+	return res, nil
+}
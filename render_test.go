@@ -0,0 +1,199 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+)
+
+const renderPassword = "s3cr3t-password"
+
+// commandLines returns the template command line and every preparatory one, joined.
+func commandLines(cmdArgs []string, prep []helmCommand) []string {
+	lines := []string{strings.Join(cmdArgs, " ")}
+	for _, p := range prep {
+		lines = append(lines, strings.Join(p.args, " "))
+	}
+	return lines
+}
+
+func TestHelmTemplateArgsKeepsPasswordOffCommandLine(t *testing.T) {
+	// A classic repository's credentials go into a private repository config.
+	rel := &ReleaseType{Chart: "nginx", RepositoryOpts: helmv3.RepositoryOpts{
+		Repo: strPtr("https://charts.example.com"), Username: strPtr("user"), Password: strPtr(renderPassword),
+	}}
+	cmdArgs, prep, cleanup, err := helmTemplateArgs(rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range commandLines(cmdArgs, prep) {
+		if strings.Contains(line, renderPassword) {
+			t.Errorf("command line %q contains the password", line)
+		}
+	}
+	if len(cmdArgs) < 3 || cmdArgs[2] != renderRepoName+"/nginx" || strings.Contains(strings.Join(cmdArgs, " "), "--repo ") {
+		t.Errorf("template args = %v, want the chart from the configured repository", cmdArgs)
+	}
+	if len(prep) != 1 || prep[0].args[0] != "repo" || prep[0].args[1] != "update" {
+		t.Fatalf("prep = %v, want a repo update", prep)
+	}
+	config := prep[0].args[3]
+	data, err := ioutil.ReadFile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), renderPassword) || !strings.Contains(string(data), "https://charts.example.com") {
+		t.Errorf("repository config = %s, want the repository and its credentials", data)
+	}
+	if fi, err := os.Stat(config); err != nil {
+		t.Fatal(err)
+	} else if fi.Mode().Perm() != 0600 {
+		t.Errorf("repository config mode = %v, want 0600", fi.Mode().Perm())
+	}
+	cleanup()
+	if _, err := os.Stat(config); !os.IsNotExist(err) {
+		t.Errorf("repository config survived cleanup: %v", err)
+	}
+
+	// An OCI registry's password is fed to `helm registry login` on stdin.
+	rel = &ReleaseType{Chart: "oci://registry.example.com:5000/charts/nginx", RepositoryOpts: helmv3.RepositoryOpts{
+		Username: strPtr("user"), Password: strPtr(renderPassword),
+	}}
+	cmdArgs, prep, cleanup, err = helmTemplateArgs(rel)
+	defer cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range commandLines(cmdArgs, prep) {
+		if strings.Contains(line, renderPassword) {
+			t.Errorf("command line %q contains the password", line)
+		}
+	}
+	if len(prep) != 1 || strings.Join(prep[0].args[:3], " ") != "registry login registry.example.com:5000" ||
+		prep[0].stdin != renderPassword {
+		t.Errorf("prep = %+v, want a registry login reading the password from stdin", prep)
+	}
+
+	// Without a password, the repository is passed as before.
+	rel = &ReleaseType{Chart: "nginx", RepositoryOpts: helmv3.RepositoryOpts{Repo: strPtr("https://charts.example.com")}}
+	cmdArgs, prep, cleanup, err = helmTemplateArgs(rel)
+	defer cleanup()
+	if err != nil || len(prep) != 0 || !strings.Contains(strings.Join(cmdArgs, " "), "nginx --repo https://charts.example.com") {
+		t.Errorf("args = %v, prep = %v, err = %v, want the repo flag", cmdArgs, prep, err)
+	}
+
+	// A password with nowhere to put it is an error, rather than silently dropped.
+	rel = &ReleaseType{Chart: "https://charts.example.com/nginx-1.2.5.tgz", RepositoryOpts: helmv3.RepositoryOpts{
+		Password: strPtr(renderPassword),
+	}}
+	_, _, cleanup, err = helmTemplateArgs(rel)
+	defer cleanup()
+	if err == nil {
+		t.Error("expected an error for a password without a repository")
+	}
+}
+
+// sampleDeployment is the manifest the sample chart renders.
+const sampleDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: apps
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.21
+`
+
+func TestRenderManifestWithFakeHelm(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no POSIX shell available")
+	}
+
+	// A fake helm that logs its command lines and input, and renders a Deployment.
+	dir := t.TempDir()
+	log := filepath.Join(dir, "helm.log")
+	manifest := filepath.Join(dir, "manifest.yaml")
+	if err := ioutil.WriteFile(manifest, []byte(sampleDeployment), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fake := "#!/bin/sh\necho \"$@\" >> '" + log + "'\ncat >> '" + log + "'\n" +
+		"if [ \"$1\" = template ]; then cat '" + manifest + "'; fi\n"
+	bin := filepath.Join(dir, "helm")
+	if err := ioutil.WriteFile(bin, []byte(fake), 0700); err != nil {
+		t.Fatal(err)
+	}
+	defer func(b string) { HelmBinary = b }(HelmBinary)
+	HelmBinary = bin
+
+	rel := &ReleaseType{Chart: "nginx", RepositoryOpts: helmv3.RepositoryOpts{
+		Repo: strPtr("https://charts.example.com"), Username: strPtr("user"), Password: strPtr(renderPassword),
+	}}
+	objs, err := RenderManifest(context.Background(), rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := objs["Deployment/apps/web"]; !ok {
+		t.Errorf("rendered objects = %v, want Deployment/apps/web", objs)
+	}
+	data, err := ioutil.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), renderPassword) {
+		t.Errorf("helm was given the password on its command line or stdin:\n%s", data)
+	}
+	if !strings.HasPrefix(string(data), "repo update ") {
+		t.Errorf("helm log =\n%s\nwant a repo update before the template", data)
+	}
+}
+
+func TestRenderManifestWithHelm(t *testing.T) {
+	if _, err := exec.LookPath(HelmBinary); err != nil {
+		t.Skip("helm is not installed")
+	}
+	dir := writeChart(t, filepath.Join(t.TempDir(), "web"), "apiVersion: v2\nname: web\nversion: 0.1.0\n")
+	tmpl := strings.Replace(sampleDeployment, "image: nginx:1.21", "image: {{ .Values.image }}", 1)
+	tmpl = strings.Replace(tmpl, "namespace: apps", "namespace: {{ .Release.Namespace }}", 1)
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "templates", "deployment.yaml"), []byte(tmpl), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	rel := &ReleaseType{Chart: dir, Namespace: strPtr("apps"), Values: map[string]interface{}{"image": "nginx:1.21"}}
+	objs, err := RenderManifest(context.Background(), rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, ok := objs["Deployment/apps/web"]
+	if !ok {
+		t.Fatalf("rendered objects = %v, want Deployment/apps/web", objs)
+	}
+	if images := ExtractImages(map[string]interface{}{"web": obj}); len(images) != 1 || images[0] != "nginx:1.21" {
+		t.Errorf("images = %v, want nginx:1.21", images)
+	}
+}
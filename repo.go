@@ -0,0 +1,302 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+	"gopkg.in/yaml.v2"
+)
+
+// RepoIndexHTTPClient is the client used to fetch Helm repository indexes. It may be
+// replaced, for instance to add authentication or to point at a fake server in tests.
+var RepoIndexHTTPClient = http.DefaultClient
+
+// RepoIndex is the subset of a Helm repository's `index.yaml` that helmbase cares about.
+type RepoIndex struct {
+	Entries map[string][]RepoIndexEntry `yaml:"entries"`
+}
+
+// RepoIndexEntry is a single chart version listed in a repository index.
+type RepoIndexEntry struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+}
+
+// ParseRepoIndex parses the contents of a Helm repository `index.yaml` file.
+func ParseRepoIndex(data []byte) (*RepoIndex, error) {
+	var idx RepoIndex
+	if err := yaml.Unmarshal(data, &idx); err != nil {
+		return nil, errors.Wrap(err, "parsing repo index")
+	}
+	return &idx, nil
+}
+
+// FetchRepoIndex downloads and parses the `index.yaml` file for the repository at
+// opts.Repo, authenticating with the options' username and password and TLS files, as
+// Helm would.
+func FetchRepoIndex(opts helmv3.RepositoryOpts) (*RepoIndex, error) {
+	data, err := fetchRepoIndexData(opts)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRepoIndex(data)
+}
+
+func fetchRepoIndexData(opts helmv3.RepositoryOpts) ([]byte, error) {
+	if opts.Repo == nil || *opts.Repo == "" {
+		return nil, errors.New("fetching repo index: no repository URL")
+	}
+	u := strings.TrimSuffix(*opts.Repo, "/") + "/index.yaml"
+	client, err := repoIndexClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetching %s", u)
+	}
+	if opts.Username != nil || opts.Password != nil {
+		req.SetBasicAuth(derefString(opts.Username), derefString(opts.Password))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetching %s", u)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("fetching %s: unexpected status %s", u, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", u)
+	}
+	return data, nil
+}
+
+// repoIndexClient returns RepoIndexHTTPClient, configured with the repository's CA and
+// client certificate files, if it has any.
+func repoIndexClient(opts helmv3.RepositoryOpts) (*http.Client, error) {
+	caFile, certFile, keyFile := derefString(opts.CaFile), derefString(opts.CertFile), derefString(opts.KeyFile)
+	if caFile == "" && certFile == "" && keyFile == "" {
+		return RepoIndexHTTPClient, nil
+	}
+
+	var tr *http.Transport
+	if t, ok := RepoIndexHTTPClient.Transport.(*http.Transport); ok && t != nil {
+		tr = t.Clone()
+	} else {
+		tr = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	if tr.TLSClientConfig == nil {
+		tr.TLSClientConfig = &tls.Config{}
+	}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading `repositoryOpts.caFile`")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("`repositoryOpts.caFile` %s holds no PEM certificates", caFile)
+		}
+		tr.TLSClientConfig.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, errors.New("`repositoryOpts.certFile` and `repositoryOpts.keyFile` must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading the repository client certificate")
+		}
+		tr.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	client := *RepoIndexHTTPClient
+	client.Transport = tr
+	return &client, nil
+}
+
+func derefString(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
+
+// RepoIndexChecksum returns the checksum of the given repository index contents, in the
+// form expected by ReleaseType.RepoIndexChecksum: the hex-encoded SHA-256 digest.
+func RepoIndexChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// OCIScheme is the URL scheme of charts stored in OCI registries.
+const OCIScheme = "oci://"
+
+// IsOCIChart reports whether the chart reference points into an OCI registry, such as
+// `oci://registry-1.docker.io/bitnamicharts/nginx`.
+func IsOCIChart(chart string) bool {
+	return strings.HasPrefix(strings.ToLower(chart), OCIScheme)
+}
+
+// Lookup verifies that the index lists the given chart. If version is non-empty, a
+// version satisfying it must be listed too; like Helm, it may be an exact version or a
+// constraint, such as `~1.2`, in the syntax accepted by VersionMatches.
+func (idx *RepoIndex) Lookup(chart, version string) error {
+	if version == "" {
+		return idx.lookupChart(chart)
+	}
+	_, err := idx.Resolve(chart, version)
+	return err
+}
+
+// lookupChart verifies that the index lists at least one version of the given chart.
+func (idx *RepoIndex) lookupChart(chart string) error {
+	entries, ok := idx.Entries[chart]
+	if !ok || len(entries) == 0 {
+		// Chart names are case-sensitive, so point out near misses that differ only in case.
+		for name := range idx.Entries {
+			if strings.EqualFold(name, chart) {
+				return errors.Errorf("chart %q not found in repo index; chart names are case-sensitive, "+
+					"did you mean %q?", chart, name)
+			}
+		}
+		return errors.Errorf("chart %q not found in repo index", chart)
+	}
+	return nil
+}
+
+// CheckRepoIndex fetches the index for the release's repository and verifies that the
+// chart and version it refers to exist. If RepoIndexChecksum is set, the index must also
+// match it exactly. Releases that don't pull from a repository, such as local chart paths,
+// are skipped.
+func CheckRepoIndex(args *ReleaseType) error {
+	repo := args.RepositoryOpts.Repo
+	if repo == nil || *repo == "" || args.Chart == "" {
+		return nil
+	}
+	data, err := fetchRepoIndexData(args.RepositoryOpts)
+	if err != nil {
+		return err
+	}
+	if want := args.RepoIndexChecksum; want != nil && *want != "" {
+		got := RepoIndexChecksum(data)
+		if !strings.EqualFold(strings.TrimPrefix(*want, "sha256:"), got) {
+			return errors.Errorf("repo index checksum mismatch: `repoIndexChecksum` is %q, but the index "+
+				"at %s has checksum %q", *want, *repo, got)
+		}
+	}
+	idx, err := ParseRepoIndex(data)
+	if err != nil {
+		return err
+	}
+	var version string
+	if args.Version != nil {
+		version = *args.Version
+	}
+	return idx.Lookup(args.Chart, version)
+}
+
+// Resolve returns a version of the chart listed in the index that satisfies the given
+// version constraint, in the syntax accepted by VersionMatches. Listed versions that
+// aren't valid semantic versions only match a constraint that names them exactly.
+func (idx *RepoIndex) Resolve(chart, constraint string) (string, error) {
+	if err := idx.lookupChart(chart); err != nil {
+		return "", err
+	}
+	entries := idx.Entries[chart]
+	for _, e := range entries {
+		if e.Version == constraint {
+			return e.Version, nil
+		}
+	}
+	// Check the constraint itself is valid, so that a typo isn't reported as a missing version.
+	if _, err := VersionMatches(constraint, "0.0.0"); err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if ok, err := VersionMatches(constraint, e.Version); err == nil && ok {
+			return e.Version, nil
+		}
+	}
+	return "", errors.Errorf("no version of chart %q in repo index satisfies %q", chart, constraint)
+}
+
+// SupportedRepoSchemes are the repository URL schemes Helm supports natively.
+var SupportedRepoSchemes = []string{"http", "https", "oci"}
+
+// PluginRepoSchemes are the repository URL schemes that Helm supports through widely used
+// downloader plugins, such as helm-s3 and helm-gcs. NormalizeRepoURL accepts them too, but
+// Warnings points out that the plugin must be installed wherever the provider runs. Others
+// may be added here.
+var PluginRepoSchemes = []string{"s3", "gs"}
+
+// ErrUnsupportedRepoScheme is returned when a repository URL uses a scheme that isn't
+// listed in SupportedRepoSchemes or PluginRepoSchemes.
+type ErrUnsupportedRepoScheme struct {
+	// Scheme is the offending scheme, e.g. "ftp".
+	Scheme string
+}
+
+func (e *ErrUnsupportedRepoScheme) Error() string {
+	return fmt.Sprintf("unsupported repository URL scheme %q; expected one of %s, or a plugin scheme such as %s",
+		e.Scheme, strings.Join(SupportedRepoSchemes, ", "), strings.Join(PluginRepoSchemes, ", "))
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isPluginRepoScheme reports whether the repository URL uses one of PluginRepoSchemes.
+func isPluginRepoScheme(repoURL string) bool {
+	u, err := url.Parse(strings.TrimSpace(repoURL))
+	return err == nil && containsString(PluginRepoSchemes, strings.ToLower(u.Scheme))
+}
+
+// NormalizeRepoURL returns the canonical form of a repository URL, with surrounding space
+// and trailing slashes trimmed and the scheme and host lowercased. URLs whose scheme is
+// neither supported natively nor by a known plugin produce an *ErrUnsupportedRepoScheme.
+func NormalizeRepoURL(repoURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(repoURL))
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing repository URL %q", repoURL)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", errors.Errorf("repository URL %q must be absolute, e.g. https://charts.example.com", repoURL)
+	}
+	u.Scheme, u.Host = strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+	if !containsString(SupportedRepoSchemes, u.Scheme) && !containsString(PluginRepoSchemes, u.Scheme) {
+		return "", &ErrUnsupportedRepoScheme{Scheme: u.Scheme}
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	return u.String(), nil
+}
@@ -0,0 +1,225 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"crypto/sha256"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/helm/v3"
+)
+
+const testRepoIndex = `apiVersion: v1
+entries:
+  nginx:
+  - name: nginx
+    version: 1.3.0
+  - name: nginx
+    version: 1.2.5
+`
+
+// serveRepoIndex serves index at /index.yaml, requiring the given basic auth credentials
+// if user is non-empty.
+func serveRepoIndex(t *testing.T, index, user, password string) *httptest.Server {
+	srv := httptest.NewServer(repoIndexHandler(index, user, password))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func repoIndexHandler(index, user, password string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if u, p, _ := r.BasicAuth(); user != "" && (u != user || p != password) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/index.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(index))
+	}
+}
+
+func TestRepoIndexLookup(t *testing.T) {
+	idx, err := ParseRepoIndex([]byte(testRepoIndex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		chart, version string
+		ok             bool
+	}{
+		{"nginx", "", true},
+		{"nginx", "1.2.5", true},
+		{"nginx", "~1.2", true},
+		{"nginx", ">=1.3.0", true},
+		{"nginx", "1.2.4", false},
+		{"nginx", "^2.0.0", false},
+		{"redis", "", false},
+	} {
+		if err := idx.Lookup(tc.chart, tc.version); (err == nil) != tc.ok {
+			t.Errorf("Lookup(%q, %q) = %v, want ok=%v", tc.chart, tc.version, err, tc.ok)
+		}
+	}
+	if v, err := idx.Resolve("nginx", "~1.2"); err != nil || v != "1.2.5" {
+		t.Errorf("Resolve(~1.2) = %q, %v, want 1.2.5", v, err)
+	}
+}
+
+func TestCheckRepoIndexPresentAndMissing(t *testing.T) {
+	srv := serveRepoIndex(t, testRepoIndex, "", "")
+	present := &ReleaseType{Chart: "nginx", Version: strPtr("~1.2"),
+		RepositoryOpts: helmv3.RepositoryOpts{Repo: strPtr(srv.URL)}}
+	if err := CheckRepoIndex(present); err != nil {
+		t.Errorf("present chart: %v", err)
+	}
+	missing := &ReleaseType{Chart: "redis", RepositoryOpts: helmv3.RepositoryOpts{Repo: strPtr(srv.URL)}}
+	if err := CheckRepoIndex(missing); err == nil || !strings.Contains(err.Error(), `chart "redis" not found`) {
+		t.Errorf("missing chart: err = %v", err)
+	}
+}
+
+func TestCheckRepoIndexUsesCredentials(t *testing.T) {
+	srv := serveRepoIndex(t, testRepoIndex, "user", "pass")
+	rel := &ReleaseType{Chart: "nginx", RepositoryOpts: helmv3.RepositoryOpts{Repo: strPtr(srv.URL)}}
+	if err := CheckRepoIndex(rel); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("without credentials: err = %v, want 401", err)
+	}
+	rel.RepositoryOpts.Username, rel.RepositoryOpts.Password = strPtr("user"), strPtr("pass")
+	if err := CheckRepoIndex(rel); err != nil {
+		t.Errorf("with credentials: %v", err)
+	}
+}
+
+func TestCheckRepoIndexUsesCAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(repoIndexHandler(testRepoIndex, "", ""))
+	defer srv.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	rel := &ReleaseType{Chart: "nginx", RepositoryOpts: helmv3.RepositoryOpts{Repo: strPtr(srv.URL)}}
+	if err := CheckRepoIndex(rel); err == nil {
+		t.Error("expected an untrusted certificate error without caFile")
+	}
+	rel.RepositoryOpts.CaFile = &caFile
+	if err := CheckRepoIndex(rel); err != nil {
+		t.Errorf("with caFile: %v", err)
+	}
+}
+
+func TestLookupCasingMismatch(t *testing.T) {
+	const index = `apiVersion: v1
+entries:
+  ingress-nginx:
+  - name: ingress-nginx
+    version: 4.0.1
+`
+	srv := serveRepoIndex(t, index, "", "")
+	idx, err := FetchRepoIndex(helmv3.RepositoryOpts{Repo: strPtr(srv.URL)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range []string{"", "4.0.1"} {
+		err := idx.Lookup("Ingress-Nginx", version)
+		if err == nil || !strings.Contains(err.Error(), `did you mean "ingress-nginx"?`) {
+			t.Errorf("Lookup(Ingress-Nginx, %q) = %v, want a casing hint", version, err)
+		}
+	}
+	if err := idx.Lookup("ingress-nginx", "4.0.1"); err != nil {
+		t.Errorf("exact casing: %v", err)
+	}
+	if err := idx.Lookup("nginx", ""); err == nil || strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("unrelated chart: err = %v, want no casing hint", err)
+	}
+}
+
+func TestCheckRepoIndexChecksum(t *testing.T) {
+	srv := serveRepoIndex(t, testRepoIndex, "", "")
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte(testRepoIndex)))
+	if got := RepoIndexChecksum([]byte(testRepoIndex)); got != sum {
+		t.Fatalf("RepoIndexChecksum = %q, want %q", got, sum)
+	}
+	for _, tc := range []struct {
+		name, checksum string
+		ok             bool
+	}{
+		{"match", sum, true},
+		{"prefixed match", "sha256:" + sum, true},
+		{"upper-case match", strings.ToUpper(sum), true},
+		{"mismatch", strings.Repeat("0", len(sum)), false},
+	} {
+		rel := &ReleaseType{Chart: "nginx", RepoIndexChecksum: strPtr(tc.checksum),
+			RepositoryOpts: helmv3.RepositoryOpts{Repo: strPtr(srv.URL)}}
+		err := CheckRepoIndex(rel)
+		if tc.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		} else if !tc.ok && (err == nil || !strings.Contains(err.Error(), "repo index checksum mismatch")) {
+			t.Errorf("%s: err = %v, want a checksum mismatch", tc.name, err)
+		}
+	}
+}
+
+func TestNormalizeRepoURL(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		err      string
+	}{
+		{"https://charts.example.com", "https://charts.example.com", ""},
+		{"  HTTPS://Charts.Example.com/stable//  ", "https://charts.example.com/stable", ""},
+		{"http://localhost:8879/charts/", "http://localhost:8879/charts", ""},
+		{"oci://registry.example.com/charts", "oci://registry.example.com/charts", ""},
+		{"s3://my-bucket/charts/", "s3://my-bucket/charts", ""},
+		{"gs://my-bucket/charts", "gs://my-bucket/charts", ""},
+		{"ftp://charts.example.com", "", `unsupported repository URL scheme "ftp"`},
+		{"charts.example.com", "", "must be absolute"},
+	} {
+		got, err := NormalizeRepoURL(tc.in)
+		if tc.err == "" {
+			if err != nil || got != tc.want {
+				t.Errorf("NormalizeRepoURL(%q) = %q, %v, want %q", tc.in, got, err, tc.want)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("NormalizeRepoURL(%q) err = %v, want %q", tc.in, err, tc.err)
+		}
+	}
+	_, err := NormalizeRepoURL("ftp://charts.example.com")
+	if e, ok := err.(*ErrUnsupportedRepoScheme); !ok || e.Scheme != "ftp" {
+		t.Errorf("err = %#v, want an *ErrUnsupportedRepoScheme for ftp", err)
+	}
+}
+
+func TestPluginRepoSchemeWarning(t *testing.T) {
+	rel := &ReleaseType{RepositoryOpts: helmv3.RepositoryOpts{Repo: strPtr("s3://my-bucket/charts")}}
+	if err := validateRepoURL(rel); err != nil {
+		t.Errorf("s3 repo: unexpected error %v", err)
+	}
+	if w := rel.Warnings(); !hasWarning(w, "relies on a Helm downloader plugin") {
+		t.Errorf("warnings = %v, want a plugin warning", w)
+	}
+	rel.RepositoryOpts.Repo = strPtr("https://charts.example.com")
+	if w := rel.Warnings(); hasWarning(w, "plugin") {
+		t.Errorf("warnings = %v, want no plugin warning for https", w)
+	}
+}
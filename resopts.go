@@ -0,0 +1,79 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ReleaseResourceOptions are Pulumi resource options applied to the Helm Release child
+// resource, given as `helmOptions.resourceOptions`. Options that refer to other resources,
+// namely `provider` and `dependsOn`, can't be expressed as plain data; set them on the
+// component instead, whose providers the Release inherits as its child, or supply a
+// provider from the chart by implementing ReleaseProviderer.
+type ReleaseResourceOptions struct {
+	// If set, protect the Release from being deleted.
+	Protect *bool `pulumi:"protect"`
+	// If set, leave the release installed in the cluster when the Release is deleted.
+	RetainOnDelete *bool `pulumi:"retainOnDelete"`
+	// If set, delete the Release before creating its replacement, rather than after.
+	DeleteBeforeReplace *bool `pulumi:"deleteBeforeReplace"`
+	// Release input properties whose changes are ignored, e.g. `values.replicaCount`.
+	IgnoreChanges []string `pulumi:"ignoreChanges"`
+	// Release input properties whose changes force a replacement rather than an upgrade.
+	ReplaceOnChanges []string `pulumi:"replaceOnChanges"`
+	// Custom timeouts for creating, updating, and deleting the Release, e.g. `10m`.
+	CustomTimeouts *ReleaseCustomTimeouts `pulumi:"customTimeouts"`
+}
+
+// ReleaseCustomTimeouts are the custom timeouts within ReleaseResourceOptions.
+type ReleaseCustomTimeouts struct {
+	Create *string `pulumi:"create"`
+	Update *string `pulumi:"update"`
+	Delete *string `pulumi:"delete"`
+}
+
+// Options returns the resource options described by o. A nil o has none.
+func (o *ReleaseResourceOptions) Options() []pulumi.ResourceOption {
+	if o == nil {
+		return nil
+	}
+	var opts []pulumi.ResourceOption
+	if o.Protect != nil {
+		opts = append(opts, pulumi.Protect(*o.Protect))
+	}
+	if o.RetainOnDelete != nil {
+		opts = append(opts, pulumi.RetainOnDelete(*o.RetainOnDelete))
+	}
+	if o.DeleteBeforeReplace != nil {
+		opts = append(opts, pulumi.DeleteBeforeReplace(*o.DeleteBeforeReplace))
+	}
+	if len(o.IgnoreChanges) > 0 {
+		opts = append(opts, pulumi.IgnoreChanges(o.IgnoreChanges))
+	}
+	if len(o.ReplaceOnChanges) > 0 {
+		opts = append(opts, pulumi.ReplaceOnChanges(o.ReplaceOnChanges))
+	}
+	if t := o.CustomTimeouts; t != nil {
+		var ct pulumi.CustomTimeouts
+		for dst, src := range map[*string]*string{&ct.Create: t.Create, &ct.Update: t.Update, &ct.Delete: t.Delete} {
+			if src != nil {
+				*dst = *src
+			}
+		}
+		opts = append(opts, pulumi.Timeouts(&ct))
+	}
+	return opts
+}
@@ -0,0 +1,121 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func TestReleaseResourceOptionsReachRelease(t *testing.T) {
+	mocks := &testMocks{}
+	args := &testArgs{Helm: &ReleaseType{ResourceOptions: &ReleaseResourceOptions{
+		Protect:             boolPtr(true),
+		RetainOnDelete:      boolPtr(true),
+		DeleteBeforeReplace: boolPtr(true),
+		IgnoreChanges:       []string{"values.replicaCount"},
+		ReplaceOnChanges:    []string{"namespace"},
+		CustomTimeouts:      &ReleaseCustomTimeouts{Create: strPtr("10m"), Delete: strPtr("5m")},
+	}}}
+	if _, err := constructMocked(t, mocks, &testChart{}, args); err != nil {
+		t.Fatal(err)
+	}
+	req := mocks.release(t).RegisterRPC
+	if !req.GetProtect() {
+		t.Error("protect = false, want true")
+	}
+	if !req.GetRetainOnDelete() {
+		t.Error("retainOnDelete = false, want true")
+	}
+	if !req.GetDeleteBeforeReplace() {
+		t.Error("deleteBeforeReplace = false, want true")
+	}
+	if got := req.GetIgnoreChanges(); !reflect.DeepEqual(got, []string{"values.replicaCount"}) {
+		t.Errorf("ignoreChanges = %v", got)
+	}
+	if got := req.GetReplaceOnChanges(); !reflect.DeepEqual(got, []string{"namespace"}) {
+		t.Errorf("replaceOnChanges = %v", got)
+	}
+	if ct := req.GetCustomTimeouts(); ct.GetCreate() != "10m" || ct.GetUpdate() != "" || ct.GetDelete() != "5m" {
+		t.Errorf("customTimeouts = %v, want create 10m and delete 5m", ct)
+	}
+
+	// Without resource options, the release isn't protected.
+	mocks = &testMocks{}
+	if _, err := constructMocked(t, mocks, &testChart{}, &testArgs{}); err != nil {
+		t.Fatal(err)
+	}
+	if mocks.release(t).RegisterRPC.GetProtect() {
+		t.Error("protect = true without resource options")
+	}
+	if opts := (*ReleaseResourceOptions)(nil).Options(); opts != nil {
+		t.Errorf("nil options = %v, want none", opts)
+	}
+}
+
+// providerChart is a chart that supplies its Release's provider, or fails to.
+type providerChart struct {
+	pulumi.ResourceState
+	chartBase
+	create bool
+	err    error
+}
+
+func (c *providerChart) ReleaseProvider(ctx *pulumi.Context, parent pulumi.Resource) (pulumi.ProviderResource, error) {
+	if !c.create || c.err != nil {
+		return nil, c.err
+	}
+	return kubernetes.NewProvider(ctx, "k8s", &kubernetes.ProviderArgs{}, pulumi.Parent(parent))
+}
+
+func TestReleaseProviderReachesRelease(t *testing.T) {
+	mocks := &testMocks{}
+	if _, err := constructMocked(t, mocks, &providerChart{create: true}, &testArgs{}); err != nil {
+		t.Fatal(err)
+	}
+	provs := mocks.byType("pulumi:providers:kubernetes")
+	if len(provs) != 1 || provs[0].Name != "k8s" {
+		t.Fatalf("providers = %v, want the chart's", provs)
+	}
+	if got := provs[0].RegisterRPC.GetParent(); !strings.HasSuffix(got, "::test") {
+		t.Errorf("provider parent = %q, want the component", got)
+	}
+	if got := mocks.release(t).Provider; !strings.Contains(got, "pulumi:providers:kubernetes::k8s::") {
+		t.Errorf("release provider = %q, want the chart's", got)
+	}
+
+	// Without one, the release inherits the component's provider, as usual.
+	mocks = &testMocks{}
+	if _, err := constructMocked(t, mocks, &providerChart{}, &testArgs{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := mocks.release(t).Provider; strings.Contains(got, "::k8s::") {
+		t.Errorf("release provider = %q, want the inherited one", got)
+	}
+
+	mocks = &testMocks{}
+	_, err := constructMocked(t, mocks, &providerChart{err: errors.New("no kubeconfig")}, &testArgs{})
+	if err == nil || !strings.Contains(err.Error(), "creating release provider: no kubeconfig") {
+		t.Errorf("err = %v, want the provider error", err)
+	}
+	if n := len(mocks.byType(testReleaseType)); n != 0 {
+		t.Errorf("%d releases created without their provider, want none", n)
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// jsonSchema is the subset of JSON Schema that we emit for chart values.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties,omitempty"`
+}
+
+// GenerateValuesSchema emits a JSON schema describing the values produced by the given
+// strongly typed args struct. Property names are driven by the `pulumi:"x"` tags, just
+// like the values decoding in InitDefaults. Pointer fields, and those tagged with
+// `,optional`, are optional; all other fields are required. The HelmOptions input is
+// omitted, since it never makes it into the resulting values.
+func GenerateValuesSchema(args interface{}) ([]byte, error) {
+	t := reflect.TypeOf(args)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.Errorf("expected a struct, got %v", t)
+	}
+
+	s := structSchema(t)
+	s.Schema = "http://json-schema.org/draft-07/schema#"
+	return json.MarshalIndent(s, "", "    ")
+}
+
+func structSchema(t reflect.Type) *jsonSchema {
+	s := &jsonSchema{Type: "object", Properties: make(map[string]*jsonSchema)}
+	for _, f := range schemaFields(t) {
+		if f.name == FieldHelmOptionsInput {
+			continue
+		}
+		if !f.optional {
+			s.Required = append(s.Required, f.name)
+		}
+		s.Properties[f.name] = typeSchema(f.typ)
+	}
+	return s
+}
+
+// schemaField is a struct field that appears in a generated schema.
+type schemaField struct {
+	name     string
+	typ      reflect.Type
+	optional bool
+}
+
+// schemaFields returns the fields of the struct t that schemas describe, named by their
+// `pulumi:"x"` tags, in order. Pointer fields, those tagged `,optional`, and every Helm
+// option are optional; all other fields are required. Both the values schema and the
+// package schema use it, so that the two agree on which fields are required.
+func schemaFields(t reflect.Type) []schemaField {
+	var res []schemaField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("pulumi")
+		if !ok || f.PkgPath != "" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		optional := f.Type.Kind() == reflect.Ptr
+		if t == reflect.TypeOf(ReleaseType{}) {
+			// Every Helm option is optional, even the chart, which defaults to the chart's own.
+			optional = true
+		}
+		for _, opt := range parts[1:] {
+			if opt == "optional" {
+				optional = true
+			}
+		}
+		res = append(res, schemaField{name: name, typ: f.Type, optional: optional})
+	}
+	return res
+}
+
+func typeSchema(t reflect.Type) *jsonSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &jsonSchema{Type: "array", Items: typeSchema(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		// Interfaces and anything else we can't describe accept any value.
+		return &jsonSchema{}
+	}
+}
+
+// pulumiTypeSpec is the subset of a Pulumi package schema type reference that we emit.
+type pulumiTypeSpec struct {
+	Type                 string          `json:"type,omitempty"`
+	Ref                  string          `json:"$ref,omitempty"`
+	Items                *pulumiTypeSpec `json:"items,omitempty"`
+	AdditionalProperties *pulumiTypeSpec `json:"additionalProperties,omitempty"`
+}
+
+// pulumiObjectSpec is the subset of a Pulumi package schema object type that we emit.
+type pulumiObjectSpec struct {
+	Type       string                     `json:"type"`
+	Properties map[string]*pulumiTypeSpec `json:"properties,omitempty"`
+	Required   []string                   `json:"required,omitempty"`
+}
+
+// pulumiResourceSpec is the subset of a Pulumi package schema resource that we emit.
+type pulumiResourceSpec struct {
+	IsComponent     bool                       `json:"isComponent"`
+	InputProperties map[string]*pulumiTypeSpec `json:"inputProperties"`
+	RequiredInputs  []string                   `json:"requiredInputs,omitempty"`
+}
+
+// releaseOutputFields are the ReleaseType fields that are outputs of the release, and so
+// don't belong in an input schema.
+var releaseOutputFields = map[string]bool{"status": true, "manifest": true}
+
+// GenerateInputSchema emits the Pulumi package schema describing the inputs of the chart
+// component with the given type token, derived from its strongly typed args struct. The
+// args struct, including its HelmOptions ReleaseType, becomes the component's input
+// properties, and every nested struct becomes an object type in the same module. The
+// result is a partial package schema holding just `resources` and `types`, suitable for
+// merging into the provider's full schema.
+func GenerateInputSchema(token string, args interface{}) ([]byte, error) {
+	if err := validateTypeToken(token); err != nil {
+		return nil, err
+	}
+	parts := strings.Split(token, ":")
+	t := reflect.TypeOf(args)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.Errorf("expected a struct, got %v", t)
+	}
+
+	g := &schemaGenerator{prefix: parts[0] + ":" + parts[1] + ":", types: make(map[string]*pulumiObjectSpec)}
+	inputs := g.object(t)
+	return json.MarshalIndent(map[string]interface{}{
+		"resources": map[string]*pulumiResourceSpec{
+			token: {IsComponent: true, InputProperties: inputs.Properties, RequiredInputs: inputs.Required},
+		},
+		"types": g.types,
+	}, "", "    ")
+}
+
+// schemaGenerator accumulates the object types referenced while generating a schema.
+type schemaGenerator struct {
+	prefix string
+	types  map[string]*pulumiObjectSpec
+}
+
+func (g *schemaGenerator) object(t reflect.Type) *pulumiObjectSpec {
+	s := &pulumiObjectSpec{Type: "object", Properties: make(map[string]*pulumiTypeSpec)}
+	for _, f := range schemaFields(t) {
+		if t == reflect.TypeOf(ReleaseType{}) && releaseOutputFields[f.name] {
+			continue
+		}
+		if !f.optional {
+			s.Required = append(s.Required, f.name)
+		}
+		s.Properties[f.name] = g.typeRef(f.typ)
+	}
+	return s
+}
+
+func (g *schemaGenerator) typeRef(t reflect.Type) *pulumiTypeSpec {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf((*pulumi.AssetOrArchive)(nil)).Elem() {
+		return &pulumiTypeSpec{Ref: "pulumi.json#/Asset"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &pulumiTypeSpec{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &pulumiTypeSpec{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &pulumiTypeSpec{Type: "number"}
+	case reflect.String:
+		return &pulumiTypeSpec{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &pulumiTypeSpec{Type: "array", Items: g.typeRef(t.Elem())}
+	case reflect.Map:
+		return &pulumiTypeSpec{Type: "object", AdditionalProperties: g.typeRef(t.Elem())}
+	case reflect.Struct:
+		tok := g.prefix + t.Name()
+		if _, ok := g.types[tok]; !ok {
+			g.types[tok] = nil // Reserve the name first, in case the type refers to itself.
+			g.types[tok] = g.object(t)
+		}
+		return &pulumiTypeSpec{Ref: "#/types/" + tok}
+	default:
+		return &pulumiTypeSpec{Ref: "pulumi.json#/Any"}
+	}
+}
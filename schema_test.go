@@ -0,0 +1,156 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type schemaArgs struct {
+	Helm     *ReleaseType      `pulumi:"helmOptions"`
+	Name     string            `pulumi:"name"`
+	Replicas *int              `pulumi:"replicas"`
+	Tags     []string          `pulumi:"tags,optional"`
+	Labels   map[string]string `pulumi:"labels"`
+	Image    struct {
+		Repository string  `pulumi:"repository"`
+		Tag        *string `pulumi:"tag"`
+	} `pulumi:"image"`
+}
+
+func TestGenerateValuesSchemaRequiredAndOptional(t *testing.T) {
+	data, err := GenerateValuesSchema(&schemaArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s jsonSchema
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"name", "labels", "image"}; !reflect.DeepEqual(s.Required, want) {
+		t.Errorf("required = %v, want %v", s.Required, want)
+	}
+	if _, ok := s.Properties[FieldHelmOptionsInput]; ok {
+		t.Errorf("helmOptions should be omitted")
+	}
+	for name, typ := range map[string]string{
+		"name": "string", "replicas": "integer", "tags": "array", "labels": "object", "image": "object",
+	} {
+		if p := s.Properties[name]; p == nil || p.Type != typ {
+			t.Errorf("property %s = %+v, want type %s", name, p, typ)
+		}
+	}
+	if img := s.Properties["image"]; img == nil || !reflect.DeepEqual(img.Required, []string{"repository"}) {
+		t.Errorf("image.required = %+v, want [repository]", img)
+	}
+}
+
+func TestGenerateValuesSchemaRejectsNonStruct(t *testing.T) {
+	if _, err := GenerateValuesSchema(42); err == nil {
+		t.Error("expected an error for a non-struct")
+	}
+}
+
+type inputSchemaImage struct {
+	Repository string  `pulumi:"repository"`
+	Tag        *string `pulumi:"tag"`
+}
+
+type inputSchemaArgs struct {
+	Helm     *ReleaseType      `pulumi:"helmOptions"`
+	Replicas *int              `pulumi:"replicas"`
+	Image    inputSchemaImage  `pulumi:"image"`
+	Labels   map[string]string `pulumi:"labels,optional"`
+}
+
+func TestGenerateInputSchemaKeyProperties(t *testing.T) {
+	const token = "nginx:index:Nginx"
+	data, err := GenerateInputSchema(token, &inputSchemaArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s struct {
+		Resources map[string]pulumiResourceSpec `json:"resources"`
+		Types     map[string]pulumiObjectSpec   `json:"types"`
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	res, ok := s.Resources[token]
+	if !ok || !res.IsComponent {
+		t.Fatalf("resources = %+v, want the %s component", s.Resources, token)
+	}
+	if want := []string{"image"}; !reflect.DeepEqual(res.RequiredInputs, want) {
+		t.Errorf("requiredInputs = %v, want %v", res.RequiredInputs, want)
+	}
+	for name, want := range map[string]pulumiTypeSpec{
+		"helmOptions": {Ref: "#/types/nginx:index:ReleaseType"},
+		"replicas":    {Type: "integer"},
+		"image":       {Ref: "#/types/nginx:index:inputSchemaImage"},
+		"labels":      {Type: "object", AdditionalProperties: &pulumiTypeSpec{Type: "string"}},
+	} {
+		if got := res.InputProperties[name]; got == nil || !reflect.DeepEqual(*got, want) {
+			t.Errorf("inputProperties.%s = %+v, want %+v", name, got, want)
+		}
+	}
+
+	rel, ok := s.Types["nginx:index:ReleaseType"]
+	if !ok {
+		t.Fatal("missing the ReleaseType object type")
+	}
+	if len(rel.Required) != 0 {
+		t.Errorf("ReleaseType required = %v, want none", rel.Required)
+	}
+	for name, want := range map[string]pulumiTypeSpec{
+		"chart":          {Type: "string"},
+		"version":        {Type: "string"},
+		"atomic":         {Type: "boolean"},
+		"timeout":        {Type: "integer"},
+		"values":         {Type: "object", AdditionalProperties: &pulumiTypeSpec{Ref: "pulumi.json#/Any"}},
+		"valueYamlFiles": {Type: "array", Items: &pulumiTypeSpec{Ref: "pulumi.json#/Asset"}},
+	} {
+		if got := rel.Properties[name]; got == nil || !reflect.DeepEqual(*got, want) {
+			t.Errorf("ReleaseType.%s = %+v, want %+v", name, got, want)
+		}
+	}
+	for _, name := range []string{"status", "manifest"} {
+		if _, ok := rel.Properties[name]; ok {
+			t.Errorf("output %s shouldn't be an input property", name)
+		}
+	}
+	img := s.Types["nginx:index:inputSchemaImage"]
+	if want := []string{"repository"}; !reflect.DeepEqual(img.Required, want) {
+		t.Errorf("image required = %v, want %v", img.Required, want)
+	}
+
+	if _, err := GenerateInputSchema("not-a-token", &inputSchemaArgs{}); err == nil {
+		t.Error("expected an error for an invalid type token")
+	}
+}
+
+func TestValueAndInputSchemasAgreeOnRequired(t *testing.T) {
+	g := &schemaGenerator{prefix: "test:index:", types: make(map[string]*pulumiObjectSpec)}
+	for _, typ := range []reflect.Type{reflect.TypeOf(schemaArgs{}), reflect.TypeOf(schemaArgs{}.Image)} {
+		values, inputs := structSchema(typ).Required, g.object(typ).Required
+		if !reflect.DeepEqual(values, inputs) {
+			t.Errorf("%v: values schema requires %v, input schema %v", typ, values, inputs)
+		}
+	}
+	if got := g.object(reflect.TypeOf(ReleaseType{})).Required; len(got) != 0 {
+		t.Errorf("Helm options required = %v, want none", got)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// StuckReleaseStatuses are the Helm release statuses left behind when an operation was
+// interrupted part way through. Helm refuses to change a release in one of these states,
+// reporting that another operation is in progress.
+var StuckReleaseStatuses = []string{"pending-install", "pending-upgrade", "pending-rollback"}
+
+// FindStuckRelease returns Helm's record of the latest revision of the named release in
+// the given namespace of the target cluster, if that revision is stuck in one of the
+// StuckReleaseStatuses, or nil. Helm stores each revision as a Secret labelled with
+// `owner=helm`, the release's `name`, and the revision's `status` and `version`; they are
+// listed with ListClusterObjects.
+func FindStuckRelease(target ClusterTarget, namespace, release string) (*ClusterObject, error) {
+	records, err := ListClusterObjects(target, "secret", namespace, "owner=helm,name="+release)
+	if err != nil {
+		return nil, err
+	}
+	var latest *ClusterObject
+	latestVersion := -1
+	for i, rec := range records {
+		v, err := strconv.Atoi(rec.Labels["version"])
+		if err != nil {
+			continue
+		}
+		if v > latestVersion {
+			latest, latestVersion = &records[i], v
+		}
+	}
+	if latest == nil || !containsString(StuckReleaseStatuses, latest.Labels["status"]) {
+		return nil, nil
+	}
+	return latest, nil
+}
+
+// CheckStuckRelease looks for the release left stuck by an interrupted Helm operation
+// (see FindStuckRelease) in the cluster the chart targets, when the release asks for it
+// with `checkStuckRelease` or `recoverStuckRelease`. A stuck release is reported to log.
+// With `recoverStuckRelease`, on update, the stuck revision's record is also deleted with
+// DeleteClusterObject, as `helm uninstall` would for it, so that Helm retries a pending
+// install from scratch, or a pending upgrade or rollback from the last good revision.
+// Previews only report what would be recovered. The release must have an explicit `name`,
+// since an auto-named release can't be found before it is created, and if the cluster
+// can't be queried the check is skipped with a warning, as CheckNamespaceCollision is.
+func CheckStuckRelease(ctx *pulumi.Context, log Logger, c Chart, args *ReleaseType) error {
+	recovery := isTrue(args.RecoverStuckRelease)
+	if !recovery && !isTrue(args.CheckStuckRelease) {
+		return nil
+	}
+	if args.Name == nil || *args.Name == "" {
+		return log.Warn("skipping the stuck release check: it needs the release's `name`")
+	}
+	name, ns := *args.Name, DefaultNamespaceFallback
+	if args.Namespace != nil && *args.Namespace != "" {
+		ns = *args.Namespace
+	}
+
+	target := ClusterTargetFor(ctx, c)
+	rec, err := FindStuckRelease(target, ns, name)
+	if err != nil {
+		return log.Warn(fmt.Sprintf("skipping the stuck release check: %v", err))
+	}
+	if rec == nil {
+		return nil
+	}
+	status, revision := rec.Labels["status"], rec.Labels["version"]
+	switch {
+	case !recovery:
+		return log.Warn(fmt.Sprintf("release %q in namespace %q is stuck in %s at revision %s, so Helm will "+
+			"refuse to change it; set `recoverStuckRelease` to clear it", name, ns, status, revision))
+	case ctx.DryRun():
+		return log.Warn(fmt.Sprintf("release %q in namespace %q is stuck in %s at revision %s; the update "+
+			"will delete that revision before retrying", name, ns, status, revision))
+	}
+	if err := DeleteClusterObject(target, "secret", ns, rec.Name); err != nil {
+		return errors.Wrapf(err, "recovering release %q stuck in %s", name, status)
+	}
+	return log.Info(fmt.Sprintf("deleted revision %s of release %q, which was stuck in %s, before retrying",
+		revision, name, status))
+}
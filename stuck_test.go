@@ -0,0 +1,190 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/provider"
+)
+
+// fakeReleaseRecords makes ListClusterObjects return the given Helm release records, and
+// DeleteClusterObject fail with deleteErr, for the rest of the test. It returns the
+// namespaced names of the objects deleted.
+func fakeReleaseRecords(t *testing.T, records []ClusterObject, deleteErr error) *[]string {
+	var deleted []string
+	oldList, oldDelete := ListClusterObjects, DeleteClusterObject
+	ListClusterObjects = func(target ClusterTarget, kind, namespace, selector string) ([]ClusterObject, error) {
+		if kind != "secret" || namespace != "apps" || selector != "owner=helm,name=web" {
+			t.Errorf("unexpected list of %s in %s matching %s", kind, namespace, selector)
+		}
+		return records, nil
+	}
+	DeleteClusterObject = func(target ClusterTarget, kind, namespace, name string) error {
+		deleted = append(deleted, kind+"/"+namespace+"/"+name)
+		return deleteErr
+	}
+	t.Cleanup(func() { ListClusterObjects, DeleteClusterObject = oldList, oldDelete })
+	return &deleted
+}
+
+// releaseRecord returns Helm's record of the given revision of the release "web".
+func releaseRecord(version, status string) ClusterObject {
+	return ClusterObject{Name: "sh.helm.release.v1.web.v" + version,
+		Labels: map[string]string{"owner": "helm", "name": "web", "version": version, "status": status}}
+}
+
+func TestFindStuckRelease(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		records []ClusterObject
+		want    string
+	}{
+		{"pending install", []ClusterObject{releaseRecord("1", "pending-install")}, "sh.helm.release.v1.web.v1"},
+		{"pending upgrade", []ClusterObject{releaseRecord("1", "deployed"), releaseRecord("2", "pending-upgrade")},
+			"sh.helm.release.v1.web.v2"},
+		// Revisions compare as numbers, not strings.
+		{"later revision deployed", []ClusterObject{releaseRecord("10", "deployed"), releaseRecord("9", "pending-upgrade")}, ""},
+		{"deployed", []ClusterObject{releaseRecord("1", "deployed")}, ""},
+		{"failed", []ClusterObject{releaseRecord("1", "failed")}, ""},
+		{"no records", nil, ""},
+	} {
+		fakeReleaseRecords(t, tc.records, nil)
+		rec, err := FindStuckRelease(ClusterTarget{}, "apps", "web")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := ""; rec != nil {
+			got = rec.Name
+			if got != tc.want {
+				t.Errorf("%s: stuck record = %q, want %q", tc.name, got, tc.want)
+			}
+		} else if tc.want != "" {
+			t.Errorf("%s: no stuck record, want %q", tc.name, tc.want)
+		}
+	}
+}
+
+// constructStuck constructs a release named "web" in namespace "apps" with the given
+// settings, returning the number of releases created and Construct's error.
+func constructStuck(t *testing.T, dryRun bool, rel *ReleaseType) (int, error) {
+	t.Helper()
+	rel.Name, rel.Namespace = strPtr("web"), strPtr("apps")
+	var construct error
+	mocks := &testMocks{}
+	err := runMocked(t, mocks, dryRun, func(ctx *pulumi.Context) error {
+		c := &testChart{}
+		_, construct = ConstructExt(ctx, c, c.Type(), "test", &testArgs{Helm: rel}, provider.ConstructInputs{}, nil)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(mocks.byType(testReleaseType)), construct
+}
+
+func TestCheckStuckReleaseRecovers(t *testing.T) {
+	stuck := []ClusterObject{releaseRecord("1", "deployed"), releaseRecord("2", "pending-upgrade")}
+
+	// Checking only reports the stuck release.
+	deleted := fakeReleaseRecords(t, stuck, nil)
+	logs := recordLogs(t)
+	if _, err := constructStuck(t, false, &ReleaseType{CheckStuckRelease: boolPtr(true)}); err != nil {
+		t.Fatal(err)
+	}
+	if !hasWarning(logs.warns, `release "web" in namespace "apps" is stuck in pending-upgrade at revision 2`) ||
+		len(*deleted) != 0 {
+		t.Errorf("warnings = %v, deleted = %v, want a warning only", logs.warns, *deleted)
+	}
+
+	// A preview says what the update will do, without doing it.
+	deleted = fakeReleaseRecords(t, stuck, nil)
+	logs = recordLogs(t)
+	if _, err := constructStuck(t, true, &ReleaseType{RecoverStuckRelease: boolPtr(true)}); err != nil {
+		t.Fatal(err)
+	}
+	if !hasWarning(logs.warns, "the update will delete that revision before retrying") || len(*deleted) != 0 {
+		t.Errorf("warnings = %v, deleted = %v, want a warning only", logs.warns, *deleted)
+	}
+
+	// The update deletes the stuck revision, then goes on to create the release.
+	deleted = fakeReleaseRecords(t, stuck, nil)
+	logs = recordLogs(t)
+	releases, err := constructStuck(t, false, &ReleaseType{RecoverStuckRelease: boolPtr(true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"secret/apps/sh.helm.release.v1.web.v2"}; !reflect.DeepEqual(*deleted, want) {
+		t.Errorf("deleted = %v, want %v", *deleted, want)
+	}
+	if releases != 1 || !hasWarning(logs.infos, "deleted revision 2 of release \"web\"") {
+		t.Errorf("releases = %d, infos = %v, want the release retried", releases, logs.infos)
+	}
+
+	// A failed recovery stops construction.
+	fakeReleaseRecords(t, stuck, errors.New("forbidden"))
+	releases, err = constructStuck(t, false, &ReleaseType{RecoverStuckRelease: boolPtr(true)})
+	if err == nil || !strings.Contains(err.Error(), "recovering release \"web\" stuck in pending-upgrade: forbidden") ||
+		releases != 0 {
+		t.Errorf("err = %v, releases = %d, want the recovery error and no release", err, releases)
+	}
+
+	// A healthy release is left alone.
+	deleted = fakeReleaseRecords(t, []ClusterObject{releaseRecord("3", "deployed")}, nil)
+	logs = recordLogs(t)
+	if _, err := constructStuck(t, false, &ReleaseType{RecoverStuckRelease: boolPtr(true)}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*deleted) != 0 || hasWarning(logs.warns, "stuck") {
+		t.Errorf("warnings = %v, deleted = %v, want nothing done", logs.warns, *deleted)
+	}
+}
+
+func TestCheckStuckReleaseSkipped(t *testing.T) {
+	old := ListClusterObjects
+	ListClusterObjects = func(ClusterTarget, string, string, string) ([]ClusterObject, error) {
+		return nil, errors.New("cluster unreachable")
+	}
+	defer func() { ListClusterObjects = old }()
+
+	logs := recordLogs(t)
+	if releases, err := constructStuck(t, false, &ReleaseType{RecoverStuckRelease: boolPtr(true)}); err != nil ||
+		releases != 1 {
+		t.Fatalf("err = %v, releases = %d, want the release created", err, releases)
+	}
+	if !hasWarning(logs.warns, "skipping the stuck release check: cluster unreachable") {
+		t.Errorf("warnings = %v, want the check skipped", logs.warns)
+	}
+
+	// Without a name, there is nothing to look for.
+	logs = recordLogs(t)
+	var construct error
+	err := runMocked(t, &testMocks{}, false, func(ctx *pulumi.Context) error {
+		c := &testChart{}
+		args := &testArgs{Helm: &ReleaseType{CheckStuckRelease: boolPtr(true)}}
+		_, construct = ConstructExt(ctx, c, c.Type(), "test", args, provider.ConstructInputs{}, nil)
+		return nil
+	})
+	if err != nil || construct != nil {
+		t.Fatal(err, construct)
+	}
+	if !hasWarning(logs.warns, "skipping the stuck release check: it needs the release's `name`") {
+		t.Errorf("warnings = %v, want the check skipped", logs.warns)
+	}
+}
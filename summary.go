@@ -0,0 +1,70 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ReleaseSummary returns a one-line, human-readable summary of what the release will do:
+// its chart, version, namespace, and repository, along with the paths of the values the
+// user set. Only value paths are included, never the values themselves, so the summary
+// is safe to log even when values hold secrets.
+func ReleaseSummary(args *ReleaseType) string {
+	orDefault := func(p *string, def string) string {
+		if p == nil || *p == "" {
+			return def
+		}
+		return *p
+	}
+
+	var userPaths []string
+	for path, src := range ValueProvenance(args) {
+		if src == ProvenanceUser {
+			userPaths = append(userPaths, path)
+		}
+	}
+	sort.Strings(userPaths)
+	values := "none"
+	if len(userPaths) > 0 {
+		values = strings.Join(userPaths, ", ")
+	}
+
+	return fmt.Sprintf("chart %s, version %s, namespace %s, repo %s; values set: %s",
+		args.Chart, orDefault(args.Version, "latest"), orDefault(args.Namespace, "(provider default)"),
+		orDefault(args.RepositoryOpts.Repo, "none"), values)
+}
+
+// ResolvedArgsDebug returns a structured, single-line description of the options that
+// will be sent to Helm, for debug logging: the chart, version, namespace, and repository,
+// plus the sorted top-level keys of the merged values. The values themselves are left
+// out, since their leaves may hold secrets.
+func ResolvedArgsDebug(args *ReleaseType) string {
+	str := func(p *string) string {
+		if p == nil {
+			return ""
+		}
+		return *p
+	}
+	keys := make([]string, 0, len(args.Values))
+	for k := range args.Values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return fmt.Sprintf("resolved helm args: chart=%q version=%q namespace=%q repo=%q valueKeys=[%s]",
+		args.Chart, str(args.Version), str(args.Namespace), str(args.RepositoryOpts.Repo), strings.Join(keys, " "))
+}
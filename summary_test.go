@@ -0,0 +1,87 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/provider"
+)
+
+// summaries returns the release summaries among the given info messages.
+func summaries(infos []string) []string {
+	var res []string
+	for _, msg := range infos {
+		if strings.HasPrefix(msg, "chart ") && strings.Contains(msg, "values set:") {
+			res = append(res, msg)
+		}
+	}
+	return res
+}
+
+func TestReleaseSummaryEmittedInPreview(t *testing.T) {
+	args := func() *testArgs {
+		return &testArgs{ReplicaCount: intPtr(2), Helm: &ReleaseType{Version: strPtr("1.2.3"),
+			Values: map[string]interface{}{"image": map[string]interface{}{"tag": "v1"}}}}
+	}
+	for _, dryRun := range []bool{true, false} {
+		logs := recordLogs(t)
+		c := &testChart{}
+		err := runMocked(t, &testMocks{}, dryRun, func(ctx *pulumi.Context) error {
+			_, err := ConstructExt(ctx, c, c.Type(), "test", args(), provider.ConstructInputs{}, nil)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := summaries(logs.infos)
+		if !dryRun {
+			if len(got) != 0 {
+				t.Errorf("update: summaries = %v, want none", got)
+			}
+			continue
+		}
+		want := "chart nginx, version 1.2.3, namespace (provider default), " +
+			"repo https://charts.example.com; values set: image.tag, replicaCount"
+		if len(got) != 1 || got[0] != want {
+			t.Errorf("preview: summaries = %v, want [%s]", got, want)
+		}
+	}
+}
+
+func TestResolvedArgsDebugLogged(t *testing.T) {
+	logs := recordLogs(t)
+	args := &testArgs{ReplicaCount: intPtr(2), Helm: &ReleaseType{Version: strPtr("1.2.3"), Namespace: strPtr("web"),
+		Values: map[string]interface{}{"db": map[string]interface{}{"password": "hunter2"}, "port": 80}}}
+	if _, err := constructMocked(t, &testMocks{}, &testChart{}, args); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, msg := range logs.debugs {
+		if strings.HasPrefix(msg, "resolved helm args: ") {
+			got = append(got, msg)
+		}
+	}
+	want := `resolved helm args: chart="nginx" version="1.2.3" namespace="web" ` +
+		`repo="https://charts.example.com" valueKeys=[db port replicaCount]`
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("debug messages = %v, want [%s]", got, want)
+	}
+	if strings.Contains(strings.Join(logs.debugs, "\n")+strings.Join(logs.infos, "\n"), "hunter2") {
+		t.Error("the password was logged")
+	}
+}
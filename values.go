@@ -0,0 +1,584 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v3/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	"gopkg.in/yaml.v2"
+)
+
+// ValuesRenderer may optionally be implemented by a Chart that produces values from some
+// other configuration language, such as CUE or Jsonnet. The rendered values are merged
+// underneath the user's values, and the strongly typed args still win over both.
+type ValuesRenderer interface {
+	Render() (map[string]interface{}, error)
+}
+
+// DefaultValuesProvider may optionally be implemented by a Chart with a curated set of
+// default values. They are merged underneath the user's values and any rendered values,
+// but over a default values file, and the strongly typed args still win over all of them.
+type DefaultValuesProvider interface {
+	DefaultValues() map[string]interface{}
+}
+
+// ValueTransform rewrites a release's final values in place, e.g. to prefix every image
+// repository with a private registry.
+type ValueTransform func(values map[string]interface{}) error
+
+// ValueTransformer may optionally be implemented by a Chart to programmatically rewrite
+// the release's values once they've been fully assembled, right before the Helm Release
+// is created. The transforms run in order, and an error from any aborts construction.
+type ValueTransformer interface {
+	ValueTransforms() []ValueTransform
+}
+
+// applyValueTransforms runs the chart's value transforms, if any, over the release's values.
+func applyValueTransforms(args *ReleaseType, c Chart) error {
+	t, ok := c.(ValueTransformer)
+	if !ok {
+		return nil
+	}
+	if args.Values == nil {
+		args.Values = make(map[string]interface{})
+	}
+	for i, transform := range t.ValueTransforms() {
+		if err := transform(args.Values); err != nil {
+			return errors.Wrapf(err, "value transform %d", i)
+		}
+	}
+	return nil
+}
+
+// DefaultValuesFiler may optionally be implemented by a Chart that ships a default values
+// file. Its contents are merged underneath the user's values, so both they and
+// the strongly typed args take precedence. A relative path is resolved against the
+// directory containing the running binary.
+type DefaultValuesFiler interface {
+	DefaultValuesFile() string
+}
+
+// resolveDefaultValuesFile resolves a chart's default values file path.
+func resolveDefaultValuesFile(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", errors.Wrap(err, "locating running binary")
+	}
+	return filepath.Join(filepath.Dir(exe), path), nil
+}
+
+// LoadValuesFile reads and parses a YAML values file, such as a checked-in `values.yaml`.
+// An empty file yields empty values.
+func LoadValuesFile(path string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading values file")
+	}
+	values, err := ParseValuesYAML(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s", path)
+	}
+	return values, nil
+}
+
+// MergeValuesFile reads the YAML values file at path and deep merges it into the
+// release's Values, so that the file wins over values already set. An empty file changes
+// nothing.
+func (r *ReleaseType) MergeValuesFile(path string) error {
+	values, err := LoadValuesFile(path)
+	if err != nil {
+		return err
+	}
+	if len(values) > 0 {
+		r.Values = MergeValuesWithStrategies(r.Values, values, arrayMergeStrategies(r))
+	}
+	return nil
+}
+
+// MergeValuesFiles merges each of the given YAML values files into the release's Values
+// in turn, as MergeValuesFile does, so that later files win over earlier ones, as with
+// repeated `helm install -f` flags. The strongly typed args are applied afterwards by
+// InitDefaults, and so still take precedence over every file. All files are read before
+// any are merged, so if one can't be loaded, Values is left unchanged.
+func (r *ReleaseType) MergeValuesFiles(paths ...string) error {
+	files := make([]map[string]interface{}, 0, len(paths))
+	for _, path := range paths {
+		values, err := LoadValuesFile(path)
+		if err != nil {
+			return err
+		}
+		files = append(files, values)
+	}
+	for _, values := range files {
+		if len(values) > 0 {
+			r.Values = MergeValuesWithStrategies(r.Values, values, arrayMergeStrategies(r))
+		}
+	}
+	return nil
+}
+
+// MergeValues deep merges src into dst and returns the result. Nested maps are merged
+// recursively, while any other value in src (including arrays) replaces the one in dst,
+// matching how Helm layers values. If dst is nil, a new map is allocated.
+func MergeValues(dst, src map[string]interface{}) map[string]interface{} {
+	return MergeValuesWithStrategies(dst, src, nil)
+}
+
+// ArrayMergeStrategy controls how an array in one set of values is combined with an array
+// at the same path in another.
+type ArrayMergeStrategy string
+
+const (
+	// ArrayMergeReplace replaces the existing array outright. This is the default, as in Helm.
+	ArrayMergeReplace ArrayMergeStrategy = "replace"
+	// ArrayMergeAppend appends the new array's elements to the existing array.
+	ArrayMergeAppend ArrayMergeStrategy = "append"
+)
+
+// MergeValuesWithStrategies behaves like MergeValues, except that arrays found at the
+// dotted paths in strategies (e.g. "controller.extraEnvs") are combined according to the
+// given strategy rather than replaced.
+func MergeValuesWithStrategies(dst, src map[string]interface{},
+	strategies map[string]ArrayMergeStrategy) map[string]interface{} {
+	return mergeValues(dst, src, strategies, "")
+}
+
+func mergeValues(dst, src map[string]interface{},
+	strategies map[string]ArrayMergeStrategy, prefix string) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+	for k, v := range src {
+		path := prefix + k
+		switch sv := v.(type) {
+		case map[string]interface{}:
+			dm, _ := dst[k].(map[string]interface{})
+			dst[k] = mergeValues(dm, sv, strategies, path+".")
+			continue
+		case []interface{}:
+			if dv, ok := dst[k].([]interface{}); ok && strategies[path] == ArrayMergeAppend {
+				merged := make([]interface{}, 0, len(dv)+len(sv))
+				dst[k] = append(append(merged, dv...), sv...)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+// ParseValuesYAML parses a YAML values document into a map suitable for merging into a
+// release's Values. An empty document yields an empty map.
+func ParseValuesYAML(data []byte) (map[string]interface{}, error) {
+	var raw map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, errors.Wrap(err, "parsing values YAML")
+	}
+	res := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		res[fmt.Sprint(k)] = normalizeYAML(v)
+	}
+	return res, nil
+}
+
+// normalizeYAML converts the map[interface{}]interface{} values produced by the YAML
+// decoder into the map[string]interface{} values that Pulumi and Helm expect.
+func normalizeYAML(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			res[fmt.Sprint(k)] = normalizeYAML(e)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(t))
+		for i, e := range t {
+			res[i] = normalizeYAML(e)
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+// ValuesFromConfigMap reads an existing ConfigMap from the cluster and parses the YAML
+// document stored under key as chart values. Because the ConfigMap is only available at
+// apply time, the result is an output that can be merged with MergeValues once resolved.
+func ValuesFromConfigMap(ctx *pulumi.Context, namespace, name, key string,
+	opts ...pulumi.ResourceOption) (pulumi.MapOutput, error) {
+	id := name
+	if namespace != "" {
+		id = namespace + "/" + name
+	}
+	cm, err := corev1.GetConfigMap(ctx, fmt.Sprintf("%s-%s-values", id, key), pulumi.ID(id), nil, opts...)
+	if err != nil {
+		return pulumi.MapOutput{}, errors.Wrapf(err, "reading configmap %s", id)
+	}
+	return cm.Data.ApplyT(func(data map[string]string) (map[string]interface{}, error) {
+		doc, ok := data[key]
+		if !ok {
+			return nil, errors.Errorf("configmap %s has no key %q", id, key)
+		}
+		return ParseValuesYAML([]byte(doc))
+	}).(pulumi.MapOutput), nil
+}
+
+// ValuesFromConfig reads chart values from the structured Pulumi config value named key in
+// each of the given config namespaces, such as `app` and `infra`, and merges them in order,
+// so later namespaces win. An empty namespace means the current project's. Namespaces
+// that don't set key are skipped. For example, with
+//
+//	pulumi config set --path infra:values.replicas 2
+//	pulumi config set --path app:values.replicas 3
+//
+// ValuesFromConfig(ctx, "values", "infra", "app") returns {"replicas": 3}.
+func ValuesFromConfig(ctx *pulumi.Context, key string, namespaces ...string) (map[string]interface{}, error) {
+	var res map[string]interface{}
+	for _, ns := range namespaces {
+		cfg := config.New(ctx, ns)
+		if cfg.Get(key) == "" {
+			continue
+		}
+		var values map[string]interface{}
+		if err := cfg.GetObject(key, &values); err != nil {
+			return nil, errors.Wrapf(err, "reading values from config %s:%s", ns, key)
+		}
+		res = MergeValues(res, values)
+	}
+	return res, nil
+}
+
+// BoolCoercion controls how scalar values are interpreted as booleans.
+type BoolCoercion int
+
+const (
+	// StrictBools only accepts actual booleans and the strings "true" and "false". Anything
+	// else, including common but ambiguous variants like "yes", is an error.
+	StrictBools BoolCoercion = iota
+	// LenientBools additionally accepts "yes"/"no", "y"/"n", "on"/"off", and "1"/"0"
+	// (ignoring case), as well as the numbers 1 and 0 of any integer or floating-point type.
+	LenientBools
+)
+
+// CoerceBool interprets a scalar from Values as a boolean according to the given mode.
+func CoerceBool(v interface{}, mode BoolCoercion) (bool, error) {
+	switch t := v.(type) {
+	case bool:
+		return t, nil
+	case string:
+		if t == "true" {
+			return true, nil
+		} else if t == "false" {
+			return false, nil
+		}
+		if mode == LenientBools {
+			switch strings.ToLower(strings.TrimSpace(t)) {
+			case "true", "yes", "y", "on", "1":
+				return true, nil
+			case "false", "no", "n", "off", "0":
+				return false, nil
+			}
+		}
+	default:
+		// Config and YAML decoding produce numbers of various kinds, e.g. int64 or float64.
+		if f, ok := numericValue(v); ok && mode == LenientBools && (f == 0 || f == 1) {
+			return f == 1, nil
+		}
+	}
+	// The value itself is left out, since it may be a secret.
+	return false, errors.Errorf("cannot interpret a %T as a boolean", v)
+}
+
+// numericValue returns the value of any integer or floating-point v as a float64.
+func numericValue(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// ValidateValueFiles checks that every value file helmbase can read up front holds YAML
+// values, catching, for instance, a binary passed by mistake. Assets backed by a local path
+// or inline text are parsed, and archives backed by a local path must exist. Remote assets
+// can't be read ahead of the install and are exempt.
+func ValidateValueFiles(files []pulumi.AssetOrArchive) error {
+	for i, f := range files {
+		var err error
+		switch t := f.(type) {
+		case pulumi.Asset:
+			switch {
+			case t.Path() != "":
+				_, err = LoadValuesFile(t.Path())
+			case t.Text() != "":
+				_, err = ParseValuesYAML([]byte(t.Text()))
+			}
+		case pulumi.Archive:
+			if t.Path() != "" {
+				_, err = os.Stat(t.Path())
+			}
+		}
+		if err != nil {
+			return errors.Wrapf(err, "valueYamlFiles[%d]", i)
+		}
+	}
+	return nil
+}
+
+// KV is a single ordered value entry. Key is a dotted path into the values, such as
+// "controller.replicaCount".
+type KV struct {
+	Key   string      `pulumi:"key"`
+	Value interface{} `pulumi:"value"`
+}
+
+// ApplyOrderedValues sets each entry onto values in sequence, creating intermediate maps
+// as needed. Because entries are applied in order, a later entry for the same path, or for
+// a parent of it, wins over an earlier one.
+func ApplyOrderedValues(values map[string]interface{}, kvs []KV) {
+	for _, kv := range kvs {
+		setValuePath(values, kv.Key, kv.Value)
+	}
+}
+
+// OrderedValuesYAML serializes the entries as a YAML values document whose keys appear in
+// first-seen order, rather than the sorted order a map would produce.
+func OrderedValuesYAML(kvs []KV) ([]byte, error) {
+	var doc yaml.MapSlice
+	for _, kv := range kvs {
+		doc = setOrderedPath(doc, strings.Split(kv.Key, "."), kv.Value)
+	}
+	return yaml.Marshal(doc)
+}
+
+// setValuePath sets v at the given dotted path within values.
+func setValuePath(values map[string]interface{}, path string, v interface{}) {
+	parts := strings.Split(path, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := values[p].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			values[p] = next
+		}
+		values = next
+	}
+	values[parts[len(parts)-1]] = v
+}
+
+// getValuePath returns the value at the given dotted path within values, if there is one.
+func getValuePath(values map[string]interface{}, path string) (interface{}, bool) {
+	parts := strings.Split(path, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := values[p].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		values = next
+	}
+	v, ok := values[parts[len(parts)-1]]
+	return v, ok
+}
+
+// setOrderedPath sets v at the given path within an ordered YAML document.
+func setOrderedPath(doc yaml.MapSlice, parts []string, v interface{}) yaml.MapSlice {
+	for i := range doc {
+		if doc[i].Key != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			doc[i].Value = v
+		} else {
+			child, _ := doc[i].Value.(yaml.MapSlice)
+			doc[i].Value = setOrderedPath(child, parts[1:], v)
+		}
+		return doc
+	}
+	if len(parts) == 1 {
+		return append(doc, yaml.MapItem{Key: parts[0], Value: v})
+	}
+	return append(doc, yaml.MapItem{Key: parts[0], Value: setOrderedPath(nil, parts[1:], v)})
+}
+
+// ValuesDecodeHooks are mapstructure decode hooks that InitDefaults runs, in order, on
+// every value decoded from a chart's strongly typed args, so that special types such as
+// durations, quantities, or enums become the values a chart expects. Each hook is called
+// with the value's type (after dereferencing pointers) and an interface{} target type,
+// and nested maps and lists are visited too. Only the values a hook changes are replaced;
+// the rest are exactly what decoding without hooks produces. The user's weakly typed
+// Values are left alone. There are none by default, and values are then copied as they are.
+var ValuesDecodeHooks []mapstructure.DecodeHookFunc
+
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// applyValuesDecodeHooks runs ValuesDecodeHooks over every value nested within values,
+// replacing only the values a hook changed.
+func applyValuesDecodeHooks(values map[string]interface{}) error {
+	for k, v := range values {
+		res, changed, err := applyValueDecodeHooks(v)
+		if err != nil {
+			return errors.Wrapf(err, "%s", k)
+		}
+		if changed {
+			values[k] = res
+		}
+	}
+	return nil
+}
+
+// applyValueDecodeHooks runs ValuesDecodeHooks over v and whatever is nested within it,
+// and reports whether any hook changed something. Values no hook changes are returned
+// exactly as they were decoded, so that, for instance, pointers and typed lists look the
+// same as they would with no hooks at all. A map or list with a changed element is
+// rebuilt as a map[string]interface{} or []interface{} to hold it.
+func applyValueDecodeHooks(v interface{}) (interface{}, bool, error) {
+	in := v
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return v, false, nil
+		}
+		in = rv.Elem().Interface()
+	}
+	if in == nil {
+		return v, false, nil
+	}
+	if m, ok := in.(map[string]interface{}); ok {
+		return v, false, applyValuesDecodeHooks(m)
+	}
+
+	// Hooks that don't recognize a value are expected to return it unchanged.
+	hook := mapstructure.ComposeDecodeHookFunc(ValuesDecodeHooks...)
+	res, err := mapstructure.DecodeHookExec(hook, reflect.TypeOf(in), interfaceType, in)
+	if err != nil {
+		return nil, false, err
+	}
+	changed := !reflect.DeepEqual(res, in)
+	if !changed {
+		res = in
+	}
+
+	rv := reflect.ValueOf(res)
+	switch {
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		m := make(map[string]interface{}, rv.Len())
+		var nested bool
+		iter := rv.MapRange()
+		for iter.Next() {
+			e, c, err := applyValueDecodeHooks(iter.Value().Interface())
+			if err != nil {
+				return nil, false, errors.Wrapf(err, "%s", iter.Key().String())
+			}
+			m[iter.Key().String()] = e
+			nested = nested || c
+		}
+		if nested {
+			return m, true, nil
+		}
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8:
+		s := make([]interface{}, rv.Len())
+		var nested bool
+		for i := range s {
+			e, c, err := applyValueDecodeHooks(rv.Index(i).Interface())
+			if err != nil {
+				return nil, false, errors.Wrapf(err, "[%d]", i)
+			}
+			s[i] = e
+			nested = nested || c
+		}
+		if nested {
+			return s, true, nil
+		}
+	}
+	if !changed {
+		return v, false, nil
+	}
+	return res, true, nil
+}
+
+// DecodeValuesInto decodes values into the strongly typed target struct, using the same
+// `pulumi:"x"` tags that drive InitDefaults. Decoding is strict: any key in values that
+// doesn't correspond to a field of target is an error. This is useful for checking that
+// merged values still match the schema a chart expects. Any values its errors would
+// include whose keys match DefaultSecretKeyPatterns are redacted.
+func DecodeValuesInto(values map[string]interface{}, target interface{}) error {
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:      target,
+		TagName:     "pulumi",
+		ErrorUnused: true,
+	})
+	if err != nil {
+		return errors.Wrap(err, "creating values decoder")
+	}
+	err = redactError(d.Decode(values), values, DefaultSecretKeyPatterns)
+	return errors.Wrap(err, "decoding values")
+}
+
+// mergeAssetValueFiles reads the release's value files that helmbase can access, namely
+// local file and inline text assets, and merges them underneath its Values, matching
+// Helm's precedence for `-f` and `--set`: files apply in order, so later files win over
+// earlier ones, and inline values win over all files. Merged files are removed from
+// ValueYamlFiles; any others, such as remote assets, are left in place.
+//
+// Merging lets chart defaults sit beneath the files, as they would in Helm, but it means
+// the files' contents become part of the Release's `values` input. Since value files
+// often hold secrets, the values of a release with merged files whose keys match the
+// given secret key patterns (see RedactionPatterns) are then marked secret, so they are
+// encrypted in state and masked in diffs. Other values stay visible in diffs.
+func mergeAssetValueFiles(args *ReleaseType, patterns []string) error {
+	var merged map[string]interface{}
+	var remaining []pulumi.AssetOrArchive
+	for i, f := range args.ValueYamlFiles {
+		a, ok := f.(pulumi.Asset)
+		if !ok || (a.Path() == "" && a.Text() == "") {
+			remaining = append(remaining, f)
+			continue
+		}
+		var values map[string]interface{}
+		var err error
+		if a.Path() != "" {
+			values, err = LoadValuesFile(a.Path())
+		} else {
+			values, err = ParseValuesYAML([]byte(a.Text()))
+		}
+		if err != nil {
+			return errors.Wrapf(err, "valueYamlFiles[%d]", i)
+		}
+		merged = MergeValuesWithStrategies(merged, values, arrayMergeStrategies(args))
+	}
+	if merged != nil {
+		layerValues(args, merged)
+		args.secretValuePatterns = append([]string{}, patterns...)
+	}
+	args.ValueYamlFiles = remaining
+	return nil
+}
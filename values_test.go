@@ -0,0 +1,807 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func TestValuesFromConfigMapMerges(t *testing.T) {
+	mocks := &testMocks{newResource: func(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+		if args.TypeToken != "kubernetes:core/v1:ConfigMap" || args.ID != "apps/overrides" {
+			t.Errorf("unexpected read of %s %s", args.TypeToken, args.ID)
+		}
+		return args.ID, resource.NewPropertyMapFromMap(map[string]interface{}{
+			"data": map[string]interface{}{"values.yaml": "replicaCount: 3\nimage:\n  tag: v2\n"},
+		}), nil
+	}}
+	var out pulumi.MapOutput
+	err := runMocked(t, mocks, false, func(ctx *pulumi.Context) error {
+		var err error
+		out, err = ValuesFromConfigMap(ctx, "apps", "overrides", "values.yaml")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides := resolve(t, out).(map[string]interface{})
+	got := MergeValues(map[string]interface{}{
+		"replicaCount": 1,
+		"image":        map[string]interface{}{"repository": "nginx", "tag": "v1"},
+	}, overrides)
+	want := map[string]interface{}{
+		"replicaCount": 3,
+		"image":        map[string]interface{}{"repository": "nginx", "tag": "v2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged values = %#v, want %#v", got, want)
+	}
+}
+
+func TestCoerceBool(t *testing.T) {
+	for _, tc := range []struct {
+		v             interface{}
+		strict, loose interface{} // The expected bool, or nil for an error.
+	}{
+		{true, true, true},
+		{false, false, false},
+		{"true", true, true},
+		{"false", false, false},
+		{"yes", nil, true},
+		{"Off", nil, false},
+		{" Y ", nil, true},
+		{"0", nil, false},
+		{1, nil, true},
+		{0, nil, false},
+		{2, nil, nil},
+		{int64(1), nil, true},
+		{int8(0), nil, false},
+		{uint32(1), nil, true},
+		{float64(1), nil, true},
+		{float32(0), nil, false},
+		{0.5, nil, nil},
+		{int64(-1), nil, nil},
+		{"maybe", nil, nil},
+		{nil, nil, nil},
+	} {
+		for _, m := range []struct {
+			mode BoolCoercion
+			want interface{}
+		}{{StrictBools, tc.strict}, {LenientBools, tc.loose}} {
+			got, err := CoerceBool(tc.v, m.mode)
+			if m.want == nil {
+				if err == nil {
+					t.Errorf("CoerceBool(%#v, %v) = %v, want an error", tc.v, m.mode, got)
+				}
+			} else if err != nil || got != m.want {
+				t.Errorf("CoerceBool(%#v, %v) = %v, %v, want %v", tc.v, m.mode, got, err, m.want)
+			}
+		}
+	}
+}
+
+// writeFile writes data to name within a temporary directory and returns its path.
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateValueFilesReadableAndMissing(t *testing.T) {
+	readable := writeFile(t, "values.yaml", "replicaCount: 2\n")
+	ok := []pulumi.AssetOrArchive{
+		pulumi.NewFileAsset(readable),
+		pulumi.NewStringAsset("image:\n  tag: v1\n"),
+		pulumi.NewRemoteAsset("https://example.com/values.yaml"),
+	}
+	if err := ValidateValueFiles(ok); err != nil {
+		t.Errorf("readable files: %v", err)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	err := ValidateValueFiles([]pulumi.AssetOrArchive{pulumi.NewFileAsset(readable), pulumi.NewFileAsset(missing)})
+	if err == nil || !strings.Contains(err.Error(), "valueYamlFiles[1]") || !strings.Contains(err.Error(), missing) {
+		t.Errorf("missing file: err = %v, want one naming valueYamlFiles[1] and %s", err, missing)
+	}
+	err = ValidateValueFiles([]pulumi.AssetOrArchive{pulumi.NewFileArchive(missing)})
+	if err == nil {
+		t.Error("missing archive: expected an error")
+	}
+}
+
+func TestValidateValueFilesYAML(t *testing.T) {
+	valid := writeFile(t, "valid.yaml", "replicaCount: 2\nimage:\n  tag: v1\n")
+	binary := writeFile(t, "chart.tgz", "\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\x03\x00\xff")
+	for _, tc := range []struct {
+		name string
+		file pulumi.AssetOrArchive
+		ok   bool
+	}{
+		{"valid file", pulumi.NewFileAsset(valid), true},
+		{"valid text", pulumi.NewStringAsset("image:\n  tag: v1\n"), true},
+		{"empty text", pulumi.NewStringAsset(""), true},
+		{"binary file", pulumi.NewFileAsset(binary), false},
+		{"malformed text", pulumi.NewStringAsset("image: [tag: v1\n"), false},
+		{"list text", pulumi.NewStringAsset("- replicaCount\n- image\n"), false},
+	} {
+		err := ValidateValueFiles([]pulumi.AssetOrArchive{tc.file})
+		if tc.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		} else if !tc.ok && (err == nil || !strings.Contains(err.Error(), "valueYamlFiles[0]")) {
+			t.Errorf("%s: err = %v, want one naming valueYamlFiles[0]", tc.name, err)
+		}
+	}
+}
+
+// renderingChart is a chart that renders values of its own.
+type renderingChart struct {
+	pulumi.ResourceState
+	chartBase
+	rendered map[string]interface{}
+	err      error
+}
+
+func (c *renderingChart) Render() (map[string]interface{}, error) { return c.rendered, c.err }
+
+func TestValuesRendererLayersUnderUserValues(t *testing.T) {
+	mocks := &testMocks{}
+	c := &renderingChart{rendered: map[string]interface{}{
+		"image":   map[string]interface{}{"repository": "nginx", "tag": "rendered"},
+		"service": map[string]interface{}{"type": "ClusterIP"},
+	}}
+	args := &testArgs{Helm: &ReleaseType{Values: map[string]interface{}{
+		"image": map[string]interface{}{"tag": "mine"},
+	}}}
+	if _, err := constructMocked(t, mocks, c, args); err != nil {
+		t.Fatal(err)
+	}
+	values := mocks.releaseValues(t)
+	wantImage := map[string]interface{}{"repository": "nginx", "tag": "mine"}
+	if got := values["image"]; !reflect.DeepEqual(got, wantImage) {
+		t.Errorf("image = %v, want %v", got, wantImage)
+	}
+	wantService := map[string]interface{}{"type": "ClusterIP"}
+	if got := values["service"]; !reflect.DeepEqual(got, wantService) {
+		t.Errorf("service = %v, want %v", got, wantService)
+	}
+}
+
+func TestValuesRendererError(t *testing.T) {
+	mocks := &testMocks{}
+	c := &renderingChart{err: errors.New("bad template")}
+	_, err := constructMocked(t, mocks, c, &testArgs{})
+	if err == nil || !strings.Contains(err.Error(), "rendering values: bad template") {
+		t.Fatalf("err = %v, want a rendering error", err)
+	}
+	if rels := mocks.byType(testReleaseType); len(rels) != 0 {
+		t.Errorf("expected no release, got %d", len(rels))
+	}
+}
+
+func TestMergeValuesArrayStrategies(t *testing.T) {
+	base := func() map[string]interface{} {
+		return map[string]interface{}{
+			"controller": map[string]interface{}{
+				"extraEnvs": []interface{}{"A=1"},
+				"args":      []interface{}{"--v=1"},
+			},
+		}
+	}
+	src := map[string]interface{}{
+		"controller": map[string]interface{}{
+			"extraEnvs": []interface{}{"B=2"},
+			"args":      []interface{}{"--v=2"},
+		},
+	}
+
+	got := MergeValues(base(), src)
+	want := map[string]interface{}{
+		"controller": map[string]interface{}{
+			"extraEnvs": []interface{}{"B=2"},
+			"args":      []interface{}{"--v=2"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replace: got %v, want %v", got, want)
+	}
+
+	dst := base()
+	dstEnvs := dst["controller"].(map[string]interface{})["extraEnvs"].([]interface{})
+	got = MergeValuesWithStrategies(dst, src, map[string]ArrayMergeStrategy{
+		"controller.extraEnvs": ArrayMergeAppend,
+		"controller.args":      ArrayMergeReplace,
+	})
+	want = map[string]interface{}{
+		"controller": map[string]interface{}{
+			"extraEnvs": []interface{}{"A=1", "B=2"},
+			"args":      []interface{}{"--v=2"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("append: got %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(dstEnvs, []interface{}{"A=1"}) {
+		t.Errorf("append modified the original array: %v", dstEnvs)
+	}
+
+	// An append strategy with nothing to append to behaves like replace.
+	got = MergeValuesWithStrategies(nil, src, map[string]ArrayMergeStrategy{"controller.extraEnvs": ArrayMergeAppend})
+	if !reflect.DeepEqual(got, src) {
+		t.Errorf("append into empty: got %v, want %v", got, src)
+	}
+}
+
+func TestOrderedValues(t *testing.T) {
+	kvs := []KV{
+		{Key: "service.type", Value: "ClusterIP"},
+		{Key: "image.tag", Value: "v1"},
+		{Key: "image.repository", Value: "nginx"},
+		{Key: "service.port", Value: 80},
+		{Key: "replicaCount", Value: 1},
+		{Key: "image.tag", Value: "v2"},
+	}
+
+	data, err := OrderedValuesYAML(kvs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "service:\n  type: ClusterIP\n  port: 80\nimage:\n  tag: v2\n  repository: nginx\nreplicaCount: 1\n"
+	if string(data) != want {
+		t.Errorf("YAML =\n%s\nwant\n%s", data, want)
+	}
+
+	values := map[string]interface{}{"replicaCount": 3}
+	ApplyOrderedValues(values, kvs)
+	wantValues := map[string]interface{}{
+		"service":      map[string]interface{}{"type": "ClusterIP", "port": 80},
+		"image":        map[string]interface{}{"tag": "v2", "repository": "nginx"},
+		"replicaCount": 1,
+	}
+	if !reflect.DeepEqual(values, wantValues) {
+		t.Errorf("values = %v, want %v", values, wantValues)
+	}
+
+	// A later entry for a parent path replaces everything beneath it.
+	values = map[string]interface{}{}
+	ApplyOrderedValues(values, []KV{{Key: "image.tag", Value: "v1"}, {Key: "image", Value: "nginx:v3"}})
+	if got := values["image"]; got != "nginx:v3" {
+		t.Errorf("image = %v, want the later parent entry", got)
+	}
+}
+
+func TestDecodeValuesInto(t *testing.T) {
+	type image struct {
+		Repository string `pulumi:"repository"`
+		Tag        string `pulumi:"tag"`
+	}
+	type target struct {
+		ReplicaCount int   `pulumi:"replicaCount"`
+		Image        image `pulumi:"image"`
+	}
+
+	var got target
+	values := map[string]interface{}{
+		"replicaCount": 3,
+		"image":        map[string]interface{}{"repository": "nginx", "tag": "v1"},
+	}
+	if err := DecodeValuesInto(values, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := target{ReplicaCount: 3, Image: image{Repository: "nginx", Tag: "v1"}}
+	if got != want {
+		t.Errorf("decoded %+v, want %+v", got, want)
+	}
+
+	extra := map[string]interface{}{
+		"replicaCount": 3,
+		"image":        map[string]interface{}{"repository": "nginx", "digest": "sha256:abc"},
+		"ingress":      map[string]interface{}{"enabled": true},
+	}
+	err := DecodeValuesInto(extra, &target{})
+	if err == nil {
+		t.Fatal("expected an error for keys the target doesn't have")
+	}
+	for _, key := range []string{"digest", "ingress"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("err = %v, want it to name %q", err, key)
+		}
+	}
+}
+
+// defaultsFileChart is a chart shipping a default values file, along with curated defaults.
+type defaultsFileChart struct {
+	pulumi.ResourceState
+	chartBase
+	file     string
+	defaults map[string]interface{}
+}
+
+func (c *defaultsFileChart) DefaultValuesFile() string             { return c.file }
+func (c *defaultsFileChart) DefaultValues() map[string]interface{} { return c.defaults }
+
+func TestDefaultValuesFilePrecedence(t *testing.T) {
+	file := writeFile(t, "defaults.yaml", "fromFile: file\ncurated: file\nuser: file\nreplicaCount: 1\n")
+	c := &defaultsFileChart{file: file, defaults: map[string]interface{}{"curated": "curated", "user": "curated"}}
+	args := &testArgs{ReplicaCount: intPtr(5), Helm: &ReleaseType{Values: map[string]interface{}{"user": "user"}}}
+
+	mocks := &testMocks{}
+	if _, err := constructMocked(t, mocks, c, args); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"fromFile": "file", "curated": "curated", "user": "user", "replicaCount": 5.0}
+	if got := mocks.releaseValues(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("values = %v, want %v", got, want)
+	}
+
+	c = &defaultsFileChart{file: filepath.Join(t.TempDir(), "missing.yaml")}
+	if _, err := constructMocked(t, &testMocks{}, c, &testArgs{}); err == nil ||
+		!strings.Contains(err.Error(), "loading default values") {
+		t.Errorf("missing default file: err = %v", err)
+	}
+}
+
+// weakArgs are chart args holding a slice of mixed scalars.
+type weakArgs struct {
+	Helm  *ReleaseType  `pulumi:"helmOptions"`
+	Flags []interface{} `pulumi:"flags"`
+	Ports []string      `pulumi:"ports"`
+}
+
+func (a *weakArgs) R() **ReleaseType { return &a.Helm }
+
+// weakDecodingChart is a chart that decodes its values with WeaklyTypedInput.
+type weakDecodingChart struct {
+	pulumi.ResourceState
+	chartBase
+}
+
+func (c *weakDecodingChart) ConfigureValuesDecoder(cfg *mapstructure.DecoderConfig) {
+	cfg.WeaklyTypedInput = true
+	cfg.Result = nil // Ignored; the release's values are always the result.
+}
+
+func TestValuesDecoderWeaklyTypedMixedSlice(t *testing.T) {
+	mocks := &testMocks{}
+	args := &weakArgs{Flags: []interface{}{1, "two", true, 3.5}, Ports: []string{"80", "443"}}
+	if _, err := constructMocked(t, mocks, &weakDecodingChart{}, args); err != nil {
+		t.Fatal(err)
+	}
+	values := mocks.releaseValues(t)
+	if want := []interface{}{1.0, "two", true, 3.5}; !reflect.DeepEqual(values["flags"], want) {
+		t.Errorf("flags = %#v, want %#v", values["flags"], want)
+	}
+	if want := []interface{}{"80", "443"}; !reflect.DeepEqual(values["ports"], want) {
+		t.Errorf("ports = %#v, want %#v", values["ports"], want)
+	}
+
+	// Decoded directly, before the values pass through the engine, each scalar keeps its type.
+	rel := &ReleaseType{}
+	if err := initDefaults(rel, "nginx", "", "", args, func(cfg *mapstructure.DecoderConfig) {
+		cfg.WeaklyTypedInput = true
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{1, "two", true, 3.5}; !reflect.DeepEqual(rel.Values["flags"], want) {
+		t.Errorf("initDefaults flags = %#v, want %#v", rel.Values["flags"], want)
+	}
+}
+
+func TestMergeAssetValueFilesPrecedenceAndSecrecy(t *testing.T) {
+	first := writeFile(t, "first.yaml", "image:\n  repository: nginx\n  tag: first\nreplicas: 1\n")
+	args := &testArgs{Helm: &ReleaseType{
+		ValueYamlFiles: []pulumi.AssetOrArchive{
+			pulumi.NewFileAsset(first),
+			pulumi.NewStringAsset("image:\n  tag: second\nservice: LoadBalancer\nauth:\n  password: hunter2\n"),
+			pulumi.NewRemoteAsset("https://example.com/values.yaml"),
+		},
+		Values: map[string]interface{}{"service": "ClusterIP"},
+	}}
+	mocks := &testMocks{}
+	c := &renderingChart{rendered: map[string]interface{}{"replicas": 3, "debug": true}}
+	if _, err := constructMocked(t, mocks, c, args); err != nil {
+		t.Fatal(err)
+	}
+
+	// Later files win over earlier ones, inline values over files, and files over defaults.
+	want := map[string]interface{}{
+		"image":    map[string]interface{}{"repository": "nginx", "tag": "second"},
+		"replicas": 1.0,
+		"service":  "ClusterIP",
+		"debug":    true,
+	}
+	got := mocks.releaseValues(t)
+	for k, v := range want {
+		if !reflect.DeepEqual(got[k], v) {
+			t.Errorf("values[%s] = %v, want %v", k, got[k], v)
+		}
+	}
+
+	// Only the values at secret keys are secret, so the rest still show in diffs.
+	inputs := mocks.release(t).Inputs
+	values := inputs["values"]
+	if values.IsSecret() || values.ObjectValue()["image"].ContainsSecrets() {
+		t.Errorf("values = %v, want only the password secret", values)
+	}
+	password := values.ObjectValue()["auth"].ObjectValue()["password"]
+	if !password.IsSecret() || password.SecretValue().Element.StringValue() != "hunter2" {
+		t.Errorf("auth.password = %v, want it secret", password)
+	}
+	if files := inputs["valueYamlFiles"]; !files.IsArray() || len(files.ArrayValue()) != 1 {
+		t.Errorf("valueYamlFiles = %v, want just the remote asset", files)
+	}
+
+	// Without files, the values stay in plain text.
+	mocks = &testMocks{}
+	if _, err := constructMocked(t, mocks, &testChart{}, &testArgs{}); err != nil {
+		t.Fatal(err)
+	}
+	if mocks.release(t).Inputs["values"].ContainsSecrets() {
+		t.Error("values without merged files shouldn't be secret")
+	}
+}
+
+func TestValuesFromConfigPrecedence(t *testing.T) {
+	config := map[string]string{
+		"infra:values":   `{"replicas": 2, "image": {"repository": "nginx", "tag": "1.20"}}`,
+		"app:values":     `{"replicas": 3, "image": {"tag": "1.21"}}`,
+		"project:values": `{"debug": true}`,
+	}
+	for _, tc := range []struct {
+		name       string
+		namespaces []string
+		want       map[string]interface{}
+	}{
+		{"later wins", []string{"infra", "app"}, map[string]interface{}{
+			"replicas": 3.0, "image": map[string]interface{}{"repository": "nginx", "tag": "1.21"},
+		}},
+		{"reversed", []string{"app", "infra"}, map[string]interface{}{
+			"replicas": 2.0, "image": map[string]interface{}{"repository": "nginx", "tag": "1.20"},
+		}},
+		{"current project and unset", []string{"", "other"}, map[string]interface{}{"debug": true}},
+		{"none set", []string{"other"}, nil},
+	} {
+		var got map[string]interface{}
+		err := runMockedConfig(t, &testMocks{}, false, config, func(ctx *pulumi.Context) error {
+			var err error
+			got, err = ValuesFromConfig(ctx, "values", tc.namespaces...)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: values = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// rejectingChart is a chart whose values decoder rejects the replica count it's given.
+type rejectingChart struct {
+	pulumi.ResourceState
+	chartBase
+}
+
+func (c *rejectingChart) ConfigureValuesDecoder(cfg *mapstructure.DecoderConfig) {
+	cfg.DecodeHook = func(from, to reflect.Type, v interface{}) (interface{}, error) {
+		if a, ok := v.(*testArgs); ok && a.ReplicaCount != nil && *a.ReplicaCount < 0 {
+			return nil, errors.Errorf("replicaCount must not be negative, got %d", *a.ReplicaCount)
+		}
+		return v, nil
+	}
+}
+
+func TestBadValuesReturnAnError(t *testing.T) {
+	for _, values := range []interface{}{"not a struct", []int{1}, map[int]string{1: "a"}} {
+		if err := InitDefaults(&ReleaseType{}, "nginx", "", "", values); err == nil ||
+			!strings.Contains(err.Error(), "decoding values") {
+			t.Errorf("InitDefaults(%#v) err = %v, want a decoding error", values, err)
+		}
+	}
+
+	mocks := &testMocks{}
+	args := &testArgs{ReplicaCount: intPtr(-1)}
+	_, err := constructMocked(t, mocks, &rejectingChart{}, args)
+	if err == nil || !strings.Contains(err.Error(), "replicaCount must not be negative") {
+		t.Errorf("construct err = %v, want the decoder's error", err)
+	}
+	if rels := mocks.byType(testReleaseType); len(rels) != 0 {
+		t.Error("a release was created despite the bad values")
+	}
+}
+
+// defaultsChart is a chart with a curated set of default values.
+type defaultsChart struct {
+	pulumi.ResourceState
+	chartBase
+}
+
+func (c *defaultsChart) DefaultValues() map[string]interface{} {
+	return map[string]interface{}{
+		"image":        map[string]interface{}{"repository": "nginx", "tag": "1.20"},
+		"service":      map[string]interface{}{"type": "ClusterIP"},
+		"replicaCount": 1,
+	}
+}
+
+func TestDefaultValuesProviderDefaultThenOverride(t *testing.T) {
+	mocks := &testMocks{}
+	if _, err := constructMocked(t, mocks, &defaultsChart{}, &testArgs{ReplicaCount: intPtr(1)}); err != nil {
+		t.Fatal(err)
+	}
+	values := mocks.releaseValues(t)
+	if got, want := values["image"], map[string]interface{}{"repository": "nginx", "tag": "1.20"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("default image = %v, want %v", got, want)
+	}
+	if got := values["service"]; !reflect.DeepEqual(got, map[string]interface{}{"type": "ClusterIP"}) {
+		t.Errorf("default service = %v", got)
+	}
+
+	// The user's values override the defaults key by key, and the typed args win over both.
+	mocks = &testMocks{}
+	args := &testArgs{ReplicaCount: intPtr(3), Helm: &ReleaseType{Values: map[string]interface{}{
+		"image":        map[string]interface{}{"tag": "1.21"},
+		"replicaCount": 2,
+	}}}
+	if _, err := constructMocked(t, mocks, &defaultsChart{}, args); err != nil {
+		t.Fatal(err)
+	}
+	values = mocks.releaseValues(t)
+	if got, want := values["image"], map[string]interface{}{"repository": "nginx", "tag": "1.21"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("overridden image = %v, want %v", got, want)
+	}
+	if got := values["service"]; !reflect.DeepEqual(got, map[string]interface{}{"type": "ClusterIP"}) {
+		t.Errorf("untouched service = %v, want the default", got)
+	}
+	if got := values["replicaCount"]; got != 3.0 {
+		t.Errorf("replicaCount = %v, want the typed arg's 3", got)
+	}
+}
+
+// mirroringChart is a chart that rewrites its image repository to a private mirror.
+type mirroringChart struct {
+	pulumi.ResourceState
+	chartBase
+	transforms []ValueTransform
+}
+
+func (c *mirroringChart) ValueTransforms() []ValueTransform { return c.transforms }
+
+// mirrorImage prefixes `image.repository` with a private registry.
+func mirrorImage(values map[string]interface{}) error {
+	image, _ := values["image"].(map[string]interface{})
+	if repo, ok := image["repository"].(string); ok {
+		image["repository"] = "mirror.example.com/" + repo
+	}
+	return nil
+}
+
+func TestValueTransformReachesRelease(t *testing.T) {
+	mocks := &testMocks{}
+	c := &mirroringChart{transforms: []ValueTransform{mirrorImage}}
+	args := &testArgs{Helm: &ReleaseType{Values: map[string]interface{}{
+		"image": map[string]interface{}{"repository": "nginx", "tag": "1.21"},
+	}}}
+	if _, err := constructMocked(t, mocks, c, args); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"repository": "mirror.example.com/nginx", "tag": "1.21"}
+	if got := mocks.releaseValues(t)["image"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("image = %v, want %v", got, want)
+	}
+
+	// A failing transform aborts construction before the release is created.
+	mocks = &testMocks{}
+	c = &mirroringChart{transforms: []ValueTransform{mirrorImage, func(map[string]interface{}) error {
+		return errors.New("registry unreachable")
+	}}}
+	_, err := constructMocked(t, mocks, c, &testArgs{})
+	if err == nil || !strings.Contains(err.Error(), "value transform 1: registry unreachable") {
+		t.Errorf("err = %v, want the failing transform's error", err)
+	}
+	if rels := mocks.byType(testReleaseType); len(rels) != 0 {
+		t.Error("a release was created despite the failing transform")
+	}
+}
+
+func TestCoerceBoolErrorOmitsValue(t *testing.T) {
+	_, err := CoerceBool("hunter2", StrictBools)
+	if err == nil || strings.Contains(err.Error(), "hunter2") || !strings.Contains(err.Error(), "string") {
+		t.Errorf("err = %v, want one naming the type but not the value", err)
+	}
+}
+
+func TestLoadValuesFile(t *testing.T) {
+	got, err := LoadValuesFile(filepath.Join("testdata", "values.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"replicaCount": 2,
+		"image":        map[string]interface{}{"repository": "nginx", "tag": "1.25"},
+		"service":      map[string]interface{}{"type": "ClusterIP", "ports": []interface{}{80, 443}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadValuesFile = %#v, want %#v", got, want)
+	}
+
+	if got, err := LoadValuesFile(filepath.Join("testdata", "empty.yaml")); err != nil || len(got) != 0 {
+		t.Errorf("empty file: LoadValuesFile = %v, %v, want empty values", got, err)
+	}
+	invalid := filepath.Join("testdata", "invalid.yaml")
+	if _, err := LoadValuesFile(invalid); err == nil || !strings.Contains(err.Error(), invalid) {
+		t.Errorf("invalid file: err = %v, want one naming the file", err)
+	}
+	if _, err := LoadValuesFile(filepath.Join("testdata", "missing.yaml")); err == nil ||
+		!strings.Contains(err.Error(), "reading values file") {
+		t.Errorf("missing file: err = %v, want a read error", err)
+	}
+}
+
+func TestMergeValuesFile(t *testing.T) {
+	r := &ReleaseType{Values: map[string]interface{}{
+		"image": map[string]interface{}{"tag": "latest", "pullPolicy": "Always"},
+		"debug": true,
+	}}
+	if err := r.MergeValuesFile(filepath.Join("testdata", "values.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	// The file wins where both set a value, and nested maps are merged.
+	want := map[string]interface{}{
+		"replicaCount": 2,
+		"image":        map[string]interface{}{"repository": "nginx", "tag": "1.25", "pullPolicy": "Always"},
+		"service":      map[string]interface{}{"type": "ClusterIP", "ports": []interface{}{80, 443}},
+		"debug":        true,
+	}
+	if !reflect.DeepEqual(r.Values, want) {
+		t.Errorf("values = %#v, want %#v", r.Values, want)
+	}
+
+	if err := r.MergeValuesFile(filepath.Join("testdata", "empty.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.Values, want) {
+		t.Errorf("an empty file changed the values to %#v", r.Values)
+	}
+	if err := r.MergeValuesFile(filepath.Join("testdata", "invalid.yaml")); err == nil {
+		t.Error("expected an error for an invalid file")
+	}
+	if !reflect.DeepEqual(r.Values, want) {
+		t.Errorf("an invalid file changed the values to %#v", r.Values)
+	}
+}
+
+func TestMergeValuesFiles(t *testing.T) {
+	r := &ReleaseType{Values: map[string]interface{}{"debug": true}}
+	err := r.MergeValuesFiles(filepath.Join("testdata", "values.yaml"), filepath.Join("testdata", "override.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The later file wins, and what it doesn't set is kept from the earlier one.
+	want := map[string]interface{}{
+		"replicaCount": 2,
+		"image":        map[string]interface{}{"repository": "nginx", "tag": "1.26"},
+		"service":      map[string]interface{}{"type": "LoadBalancer", "ports": []interface{}{80, 443}},
+		"debug":        true,
+	}
+	if !reflect.DeepEqual(r.Values, want) {
+		t.Errorf("values = %#v, want %#v", r.Values, want)
+	}
+
+	// A missing file is reported, and nothing is merged, not even the files before it.
+	r = &ReleaseType{Values: map[string]interface{}{"debug": true}}
+	missing := filepath.Join("testdata", "missing.yaml")
+	err = r.MergeValuesFiles(filepath.Join("testdata", "values.yaml"), missing)
+	if err == nil || !strings.Contains(err.Error(), "reading values file") || !strings.Contains(err.Error(), missing) {
+		t.Errorf("err = %v, want one naming the missing file", err)
+	}
+	if want := map[string]interface{}{"debug": true}; !reflect.DeepEqual(r.Values, want) {
+		t.Errorf("values = %#v after a failed merge, want them unchanged", r.Values)
+	}
+}
+
+// durationHook renders durations the way charts expect them, e.g. "1m30s".
+func durationHook(from, to reflect.Type, v interface{}) (interface{}, error) {
+	if d, ok := v.(time.Duration); ok {
+		return d.String(), nil
+	}
+	return v, nil
+}
+
+type hookedPorts struct {
+	Port *int          `pulumi:"port"`
+	Wait time.Duration `pulumi:"wait"`
+}
+
+type hookedArgs struct {
+	Timeout  time.Duration   `pulumi:"timeout"`
+	Backoff  *time.Duration  `pulumi:"backoff"`
+	Delays   []time.Duration `pulumi:"delays"`
+	Replicas *int            `pulumi:"replicas"`
+	Tags     []string        `pulumi:"tags"`
+	Labels   map[string]int  `pulumi:"labels"`
+	Ports    hookedPorts     `pulumi:"ports"`
+	Probe    *hookedPorts    `pulumi:"probe"`
+}
+
+// decodeWithHooks runs InitDefaults over args with the given ValuesDecodeHooks.
+func decodeWithHooks(t *testing.T, args interface{}, hooks ...mapstructure.DecodeHookFunc) map[string]interface{} {
+	t.Helper()
+	old := ValuesDecodeHooks
+	ValuesDecodeHooks = hooks
+	defer func() { ValuesDecodeHooks = old }()
+	rel := &ReleaseType{}
+	if err := InitDefaults(rel, "nginx", "", "", args); err != nil {
+		t.Fatal(err)
+	}
+	return rel.Values
+}
+
+func TestValuesDecodeHooksDuration(t *testing.T) {
+	backoff := 5 * time.Second
+	args := &hookedArgs{
+		Timeout:  90 * time.Second,
+		Backoff:  &backoff,
+		Delays:   []time.Duration{time.Second, 2 * time.Second},
+		Replicas: intPtr(2),
+		Tags:     []string{"web"},
+		Labels:   map[string]int{"tier": 1},
+		Ports:    hookedPorts{Port: intPtr(80), Wait: 3 * time.Second},
+		Probe:    &hookedPorts{Port: intPtr(8080)},
+	}
+	plain := decodeWithHooks(t, args)
+	hooked := decodeWithHooks(t, args, durationHook)
+
+	for k, want := range map[string]interface{}{
+		"timeout": "1m30s",
+		"backoff": "5s",
+		"delays":  []interface{}{"1s", "2s"},
+	} {
+		if got := hooked[k]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %#v, want %#v", k, got, want)
+		}
+	}
+	ports := hooked["ports"].(map[string]interface{})
+	if ports["wait"] != "3s" {
+		t.Errorf("ports.wait = %#v, want 3s", ports["wait"])
+	}
+
+	// Fields no hook touches come out exactly as they do without hooks: pointers aren't
+	// dereferenced, typed lists and maps keep their types, and nested structs are maps.
+	for _, k := range []string{"replicas", "tags", "labels", "probe"} {
+		if reflect.TypeOf(hooked[k]) != reflect.TypeOf(plain[k]) || !reflect.DeepEqual(hooked[k], plain[k]) {
+			t.Errorf("%s = %#v, want %#v, as without hooks", k, hooked[k], plain[k])
+		}
+	}
+	if hooked["replicas"] != plain["replicas"] {
+		t.Error("replicas was copied, want the decoded pointer kept")
+	}
+	plainPorts := plain["ports"].(map[string]interface{})
+	if reflect.TypeOf(ports["port"]) != reflect.TypeOf(plainPorts["port"]) {
+		t.Errorf("ports.port = %#v, want %#v, as without hooks", ports["port"], plainPorts["port"])
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+)
+
+// VersionMatches reports whether version satisfies the given constraint, using the same
+// constraint syntax Helm accepts for chart versions: an exact version, comparison ranges
+// such as ">=1.2.0 <2.0.0", caret and tilde ranges such as "^1.2.0" and "~1.2.0", and
+// wildcards such as "1.2.x" or "*". Alternatives may be combined with "||". As in Helm,
+// partial versions such as "1.2" are completed with zeros, so ">=1.2" means ">=1.2.0",
+// and a bare partial version matches every version it prefixes.
+func VersionMatches(constraint, version string) (bool, error) {
+	v, err := semver.ParseTolerant(version)
+	if err != nil {
+		return false, errors.Wrapf(err, "parsing version %q", version)
+	}
+	for _, alt := range strings.Split(constraint, "||") {
+		r, err := parseVersionRange(strings.TrimSpace(alt))
+		if err != nil {
+			return false, err
+		}
+		if r(v) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// parseVersionRange converts a single, space-separated set of constraints into a range.
+func parseVersionRange(constraint string) (semver.Range, error) {
+	r := semver.Range(func(semver.Version) bool { return true })
+	var op string
+	for _, c := range strings.Fields(constraint) {
+		// Allow a space between an operator and its version, as in ">= 1.2.0".
+		if strings.Trim(c, "<>=!^~") == "" {
+			op += c
+			continue
+		}
+		c, op = op+c, ""
+		cr, err := parseConstraint(c)
+		if err != nil {
+			return nil, err
+		}
+		r = r.AND(cr)
+	}
+	if op != "" {
+		return nil, errors.Errorf("parsing version constraint %q: no version after %q", constraint, op)
+	}
+	return r, nil
+}
+
+// parseConstraint converts a single constraint, such as ">=1.2" or "^0.2.3", into a range,
+// following the semantics of the Masterminds/semver library Helm uses.
+func parseConstraint(c string) (semver.Range, error) {
+	rest := strings.TrimLeft(c, "<>=!^~")
+	op := c[:len(c)-len(rest)]
+	v, n, err := parsePartialVersion(rest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing version constraint %q", c)
+	}
+	// Every version sharing the given prefix is at least v and below next. A wildcard
+	// prefix, with no segments at all, covers every version.
+	next := v
+	switch n {
+	case 1:
+		next = semver.Version{Major: v.Major + 1}
+	case 2:
+		next = semver.Version{Major: v.Major, Minor: v.Minor + 1}
+	}
+	all := func(semver.Version) bool { return true }
+	none := func(semver.Version) bool { return false }
+	prefix := func(x semver.Version) bool { return n == 0 || x.GTE(v) && x.LT(next) }
+
+	switch op {
+	case "", "=", "==":
+		if n == 3 {
+			return v.EQ, nil
+		}
+		return prefix, nil
+	case "!=":
+		if n == 3 {
+			return v.NE, nil
+		}
+		return func(x semver.Version) bool { return !prefix(x) }, nil
+	case ">":
+		switch n {
+		case 0:
+			return none, nil
+		case 3:
+			return v.LT, nil
+		}
+		return next.LTE, nil
+	case ">=", "=>":
+		return v.LTE, nil
+	case "<":
+		if n == 0 {
+			return none, nil
+		}
+		return v.GT, nil
+	case "<=", "=<":
+		switch n {
+		case 0:
+			return all, nil
+		case 3:
+			return v.GTE, nil
+		}
+		return next.GT, nil
+	case "~", "~>":
+		// A tilde range allows patch-level changes, or minor-level ones when only the
+		// major version is given: "~1.2.3" is "<1.3.0", but "~1" is "<2.0.0".
+		upper := semver.Version{Major: v.Major, Minor: v.Minor + 1}
+		if n == 1 {
+			upper = next
+		}
+		return func(x semver.Version) bool { return n == 0 || x.GTE(v) && x.LT(upper) }, nil
+	case "^":
+		// A caret range allows changes that don't modify the leftmost non-zero segment
+		// given: "^1.2.3" is "<2.0.0", "^0.2.3" is "<0.3.0", and "^0.0.3" is "<0.0.4".
+		var upper semver.Version
+		switch {
+		case v.Major > 0 || n == 1:
+			upper = semver.Version{Major: v.Major + 1}
+		case v.Minor > 0 || n == 2:
+			upper = semver.Version{Minor: v.Minor + 1}
+		default:
+			upper = semver.Version{Patch: v.Patch + 1}
+		}
+		return func(x semver.Version) bool { return n == 0 || x.GTE(v) && x.LT(upper) }, nil
+	}
+	return nil, errors.Errorf("parsing version constraint %q: unknown operator %q", c, op)
+}
+
+// parsePartialVersion parses a possibly partial version, such as "1", "1.2", "1.2.x", or
+// "*", completing any missing or wildcard segments with zeros. It also returns how many
+// segments were given before the first missing or wildcard one, from 0 to 3.
+func parsePartialVersion(s string) (semver.Version, int, error) {
+	s = strings.TrimPrefix(s, "v")
+	if v, err := semver.Parse(s); err == nil {
+		return v, 3, nil
+	}
+	var segs []uint64
+	for _, seg := range strings.Split(s, ".") {
+		if seg == "x" || seg == "X" || seg == "*" {
+			break
+		}
+		n, err := strconv.ParseUint(seg, 10, 64)
+		if err != nil || len(segs) == 3 {
+			return semver.Version{}, 0, errors.Errorf("invalid version %q", s)
+		}
+		segs = append(segs, n)
+	}
+	segs = append(segs, 0, 0, 0)
+	return semver.Version{Major: segs[0], Minor: segs[1], Patch: segs[2]}, len(segs) - 3, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2021, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmbase
+
+import "testing"
+
+func TestVersionMatches(t *testing.T) {
+	for _, tc := range []struct {
+		constraint, version string
+		want                bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{"v1.2.3", "1.2.3", true},
+		{"1.2", "1.2.9", true},
+		{"1.2", "1.3.0", false},
+		{"1.2.x", "1.2.9", true},
+		{"*", "0.0.1", true},
+		{">= 1.2.0", "1.2.0", true},
+		{">=1.2.0 <2.0.0", "2.0.0", false},
+		{"<1.0.0 || >=2.0.0", "2.1.0", true},
+
+		// Partial versions are completed with zeros.
+		{">=1.2", "1.2.0", true},
+		{">=1.2", "1.1.9", false},
+		{">1.2", "1.2.5", false},
+		{">1.2", "1.3.0", true},
+		{"<1.2", "1.1.9", true},
+		{"<1.2", "1.2.0", false},
+		{"<=1.2", "1.2.9", true},
+		{"<=1.2", "1.3.0", false},
+		{"!=1.2", "1.2.5", false},
+		{"!=1.2", "1.3.0", true},
+
+		// Tilde ranges allow patch-level changes, or minor-level ones for a major version.
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"~1.2", "1.2.0", true},
+		{"~1", "1.9.0", true},
+		{"~1", "2.0.0", false},
+		{"~1.x", "1.9.0", true},
+
+		// Caret ranges allow changes that keep the leftmost non-zero segment.
+		{"^1.2.3", "1.9.0", true},
+		{"^1.2.3", "2.0.0", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.3", true},
+		{"^0.0.3", "0.0.4", false},
+		{"^0.0", "0.0.9", true},
+		{"^0.0", "0.1.0", false},
+		{"^0", "0.9.0", true},
+		{"^0", "1.0.0", false},
+	} {
+		got, err := VersionMatches(tc.constraint, tc.version)
+		if err != nil {
+			t.Errorf("VersionMatches(%q, %q): %v", tc.constraint, tc.version, err)
+		} else if got != tc.want {
+			t.Errorf("VersionMatches(%q, %q) = %v, want %v", tc.constraint, tc.version, got, tc.want)
+		}
+	}
+
+	for _, c := range []string{"1.2.3.4", "abc", ">=", "%1.2"} {
+		if _, err := VersionMatches(c, "1.2.3"); err == nil {
+			t.Errorf("VersionMatches(%q) succeeded, want an error", c)
+		}
+	}
+}